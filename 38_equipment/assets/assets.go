@@ -0,0 +1,128 @@
+// Package assets finds the files the game and its UIs load. They are all built into the
+// binary, so it runs from any directory. Setting Dir, with -assets, reads files from a
+// directory laid out like 38_equipment instead, wherever it has them, and watches them
+// so changes show up without a restart.
+package assets
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Dir overrides built in files with the ones under it, eg. "." to work on them in this directory
+var Dir string
+
+//go:embed ui
+var uiFiles embed.FS
+
+// UI is the atlas, fonts and sounds. tiles.png isn't in git, so it's only
+// built in if it was copied to assets/ui, or made there with atlaspack.
+var UI = New(uiFiles, "assets")
+
+// Set is one package's built in files. Files are named by their path from 38_equipment,
+// eg. game/maps/level1.map, the same in Dir as in the code.
+type Set struct {
+	files  fs.FS
+	prefix string // Directory the package embedded them from
+}
+
+// New wraps the files a package embedded, prefix is where that package is, eg. "game"
+func New(files fs.FS, prefix string) *Set {
+	return &Set{files, prefix}
+}
+
+// Path is where a file goes on disk, for files the game writes back, eg. bindings
+func Path(name string) string {
+	return filepath.Join(Dir, filepath.FromSlash(name))
+}
+
+// embedded turns a name into its path in the embedded files
+func (s *Set) embedded(name string) (string, bool) {
+	if !strings.HasPrefix(name, s.prefix+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(name, s.prefix+"/"), true
+}
+
+// Read returns a file, from Dir if it's there or else built in
+func (s *Set) Read(name string) ([]byte, error) {
+	if Dir != "" {
+		data, err := os.ReadFile(Path(name))
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if embedded, ok := s.embedded(name); ok {
+		data, err := fs.ReadFile(s.files, embedded)
+		if err == nil {
+			return data, nil
+		}
+	}
+	if Dir != "" {
+		return nil, &MissingError{name, Dir}
+	}
+	return nil, &MissingError{name, ""}
+}
+
+// MustRead is Read for files we can't go on without
+func (s *Set) MustRead(name string) []byte {
+	data, err := s.Read(name)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// MissingError says where we looked for a file
+type MissingError struct {
+	Name string
+	Dir  string
+}
+
+func (e *MissingError) Error() string {
+	if e.Dir == "" {
+		return "Missing asset " + e.Name + ": it isn't built in, and there's no -assets directory to look in"
+	}
+	return "Missing asset " + e.Name + ": it isn't built in, or in the -assets directory " + e.Dir
+}
+
+// Glob lists files matching a pattern, eg. game/maps/*.map, from Dir and built in together
+func (s *Set) Glob(pattern string) []string {
+	found := make(map[string]bool)
+	if Dir != "" {
+		matches, err := filepath.Glob(Path(pattern))
+		if err != nil {
+			panic(err)
+		}
+		for _, match := range matches {
+			rel, err := filepath.Rel(Dir, match)
+			if err != nil {
+				panic(err)
+			}
+			found[filepath.ToSlash(rel)] = true
+		}
+	}
+	if embedded, ok := s.embedded(pattern); ok {
+		matches, err := fs.Glob(s.files, embedded)
+		if err != nil {
+			panic(err)
+		}
+		for _, match := range matches {
+			found[path.Join(s.prefix, match)] = true
+		}
+	}
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
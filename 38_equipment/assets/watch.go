@@ -0,0 +1,63 @@
+package assets
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// How often Watch looks at files. Polling is plenty for a few files, and needs nothing outside Go.
+const watchInterval = 500 * time.Millisecond
+
+// fileState is what changes when a file is saved
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch sends the name of every file in Dir matching the patterns when it changes or
+// appears, eg. Watch("game/maps/*.map"). Built in files never change, so without Dir
+// nothing is ever sent. Read the channel often, the watcher waits until you do.
+func Watch(patterns ...string) <-chan string {
+	changes := make(chan string, 16)
+	if Dir == "" {
+		return changes
+	}
+	go func() {
+		last := scan(patterns)
+		for {
+			time.Sleep(watchInterval)
+			now := scan(patterns)
+			for name, state := range now {
+				if before, ok := last[name]; !ok || before != state {
+					changes <- name
+				}
+			}
+			last = now
+		}
+	}()
+	return changes
+}
+
+// scan stats every file in Dir matching the patterns
+func scan(patterns []string) map[string]fileState {
+	states := make(map[string]fileState)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(Path(pattern))
+		if err != nil {
+			panic(err)
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				continue // Deleted since we globbed
+			}
+			rel, err := filepath.Rel(Dir, match)
+			if err != nil {
+				panic(err)
+			}
+			states[filepath.ToSlash(rel)] = fileState{info.ModTime(), info.Size()}
+		}
+	}
+	return states
+}
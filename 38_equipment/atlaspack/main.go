@@ -0,0 +1,219 @@
+package main
+
+// Packs a directory of sprites into one image, and writes the atlas index ui2d reads:
+//
+//	go run ./atlaspack -in sprites -out assets/ui/tiles.png -index assets/ui/atlas.txt
+//
+// Every PNG becomes a sprite named after its file, eg. Rat.png is "Rat". Files like
+// StoneWall_0.png, StoneWall_1.png... are the pictures of one sprite, in number order,
+// either variations or animation frames. sprites.txt in the directory adds lines like
+// rune, frames and auto4 to sprites, written the same way as in the index.
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// picture is one PNG going into the atlas
+type picture struct {
+	name  string // Sprite it belongs to
+	index int    // Order within the sprite
+	img   image.Image
+	x, y  int // Where it was packed
+}
+
+func main() {
+	in := flag.String("in", "sprites", "directory of sprite PNGs")
+	out := flag.String("out", "tiles.png", "atlas image to write")
+	index := flag.String("index", "atlas.txt", "atlas index to write")
+	width := flag.Int("width", 1024, "width of the atlas, unless a sprite is wider")
+	pad := flag.Int("pad", 1, "pixels between sprites, so they don't bleed into each other when scaled")
+	flag.Parse()
+
+	pictures := loadPictures(*in)
+	if len(pictures) == 0 {
+		panic("No PNGs in " + *in)
+	}
+	extra := loadExtra(filepath.Join(*in, "sprites.txt"))
+	for name := range extra {
+		if !hasSprite(pictures, name) {
+			panic("sprites.txt has lines for " + name + ", but there is no " + name + ".png")
+		}
+	}
+
+	w, h := pack(pictures, *width, *pad)
+	atlas := image.NewRGBA(image.Rect(0, 0, w, h))
+	for _, p := range pictures {
+		b := p.img.Bounds()
+		draw.Draw(atlas, image.Rect(p.x, p.y, p.x+b.Dx(), p.y+b.Dy()), p.img, b.Min, draw.Src)
+	}
+	writePNG(*out, atlas)
+	writeIndex(*index, pictures, extra)
+	fmt.Printf("Packed %d pictures into %dx%d %s\n", len(pictures), w, h, *out)
+}
+
+// loadPictures reads every PNG in a directory, sorted by sprite then number
+func loadPictures(dir string) []*picture {
+	files, err := filepath.Glob(filepath.Join(dir, "*.png"))
+	if err != nil {
+		panic(err)
+	}
+	pictures := make([]*picture, 0, len(files))
+	for _, file := range files {
+		name, index := spriteName(strings.TrimSuffix(filepath.Base(file), ".png"))
+		infile, err := os.Open(file)
+		if err != nil {
+			panic(err)
+		}
+		img, err := png.Decode(infile)
+		infile.Close()
+		if err != nil {
+			panic(file + ": " + err.Error())
+		}
+		pictures = append(pictures, &picture{name, index, img, 0, 0})
+	}
+	sort.Slice(pictures, func(i, j int) bool {
+		if pictures[i].name != pictures[j].name {
+			return pictures[i].name < pictures[j].name
+		}
+		return pictures[i].index < pictures[j].index
+	})
+	return pictures
+}
+
+// spriteName splits "StoneWall_3" into the sprite and which of its pictures it is
+func spriteName(base string) (string, int) {
+	i := strings.LastIndex(base, "_")
+	if i < 0 {
+		return base, 0
+	}
+	index, err := strconv.Atoi(base[i+1:])
+	if err != nil {
+		return base, 0 // Just an underscore in the name
+	}
+	return base[:i], index
+}
+
+func hasSprite(pictures []*picture, name string) bool {
+	for _, p := range pictures {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// loadExtra reads sprites.txt, if there is one, into lines to add under each sprite
+func loadExtra(filename string) map[string][]string {
+	extra := make(map[string][]string)
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return extra
+	}
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	current := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '@' {
+			current = strings.TrimSpace(line[1:])
+			continue
+		}
+		if current == "" {
+			panic("Line before first sprite in " + filename + ": " + line)
+		}
+		keyword := strings.Fields(line)[0]
+		if keyword == "rect" || keyword == "cells" {
+			panic("Pictures come from the PNGs, not " + filename + ": " + line)
+		}
+		extra[current] = append(extra[current], line)
+	}
+	if err := scanner.Err(); err != nil {
+		panic(err)
+	}
+	return extra
+}
+
+// pack places pictures in rows, tallest first so rows waste less space, and returns the atlas size
+func pack(pictures []*picture, width, pad int) (int, int) {
+	for _, p := range pictures {
+		if w := p.img.Bounds().Dx(); w > width {
+			width = w
+		}
+	}
+	order := make([]*picture, len(pictures))
+	copy(order, pictures)
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].img.Bounds().Dy() > order[j].img.Bounds().Dy()
+	})
+
+	x, y, rowHeight, usedWidth := 0, 0, 0, 0
+	for _, p := range order {
+		b := p.img.Bounds()
+		if x > 0 && x+b.Dx() > width {
+			// Start a new row
+			x = 0
+			y += rowHeight + pad
+			rowHeight = 0
+		}
+		p.x, p.y = x, y
+		x += b.Dx() + pad
+		if b.Dy() > rowHeight {
+			rowHeight = b.Dy()
+		}
+		if x-pad > usedWidth {
+			usedWidth = x - pad
+		}
+	}
+	return usedWidth, y + rowHeight
+}
+
+func writePNG(filename string, img image.Image) {
+	outfile, err := os.Create(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer outfile.Close()
+	err = png.Encode(outfile, img)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// writeIndex writes every sprite with the rects of its pictures, in the format ui2d/sprites.go reads
+func writeIndex(filename string, pictures []*picture, extra map[string][]string) {
+	var sb strings.Builder
+	sb.WriteString("# Made by atlaspack, edit the sprites and run it again instead\n")
+	current := ""
+	for _, p := range pictures {
+		if p.name != current {
+			current = p.name
+			sb.WriteString("\n@" + p.name + "\n")
+			for _, line := range extra[p.name] {
+				sb.WriteString(line + "\n")
+			}
+		}
+		b := p.img.Bounds()
+		sb.WriteString(fmt.Sprintf("rect %d,%d,%d,%d\n", p.x, p.y, b.Dx(), b.Dy()))
+	}
+	err := os.WriteFile(filename, []byte(sb.String()), 0644)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,161 @@
+package game
+
+import (
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Bots stand in for UIs, reading everything they are sent while the game carries
+// on, the same as ui2d does while drawing. Run with -race, this checks the game
+// never changes a level a UI is still looking at:
+//
+//	go test -race -run Bots ./game
+
+const (
+	botPlayers = 4
+	botTurns   = 200 // Inputs each bot sends before closing its window, unless it gets hurt first
+)
+
+// Only inputs that can't go stale, since other bots move things around in between
+var botMoves = []InputType{Up, Down, Left, Right, Up, Down, Left, Right, TakeAll, Search, Talk, LeaveShop}
+
+func TestBots(t *testing.T) {
+	playBots(t, false)
+}
+
+func TestBotsWithUpdates(t *testing.T) {
+	playBots(t, true)
+}
+
+func playBots(t *testing.T, useUpdates bool) {
+	inTempDir(t) // Anyone who dies leaves a morgue file behind
+	g := NewGame(botPlayers)
+	var wg sync.WaitGroup
+	for i := 0; i < botPlayers; i++ {
+		var updates chan *Update
+		if useUpdates {
+			updates = g.Updates(g.LevelChans[i])
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bot(g.InputChan, g.LevelChans[i], updates, rand.New(rand.NewSource(int64(i))))
+		}(i) // Loop will finish quickly, so pass i in
+	}
+
+	done := make(chan struct{})
+	go func() {
+		g.Run() // Returns once every bot has closed its window
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Minute):
+		t.Fatal("Game didn't finish, something is stuck")
+	}
+	if g.Turn == 0 {
+		t.Fatal("No turns were played")
+	}
+	t.Log("Played", g.Turn, "turns")
+}
+
+// inTempDir runs the rest of the test somewhere it can write files, everything it reads is built in
+func inTempDir(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// bot plays like a UI, always ready to take a new level while it waits to send input
+func bot(inputChan chan *Input, levelChan chan *Level, updates chan *Update, r *rand.Rand) {
+	levels := levelChan
+	if updates != nil {
+		levels = nil // Build levels up from updates instead
+	}
+	var level *Level
+	for sent := 0; ; {
+		input := &Input{Typ: botMoves[r.Intn(len(botMoves))], LevelChannel: levelChan}
+		if sent >= botTurns || level != nil && level.Player.Hitpoints < level.Player.MaxHitpoints/2 {
+			input.Typ = CloseWindow // Leave before the monsters end the run
+		}
+		select {
+		case next, ok := <-levels:
+			if !ok {
+				return
+			}
+			level = next
+			look(level)
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			level = update.Apply(level)
+			look(level)
+		case inputChan <- input:
+			sent++
+			if input.Typ == CloseWindow {
+				inputChan = nil // Only close once, then keep reading until the game closes our channel
+			}
+		}
+	}
+}
+
+// look reads every part of the level a UI might draw
+func look(level *Level) int {
+	seen := 0
+	for _, row := range level.Map {
+		for _, tile := range row {
+			if tile.Visible || tile.Seen || tile.OverlayRune != Blank {
+				seen++
+			}
+		}
+	}
+	for _, player := range level.Players {
+		seen += player.X + player.Y
+	}
+	for _, monster := range level.Monsters {
+		seen += monster.Hitpoints
+	}
+	for _, npc := range level.NPCs {
+		seen += len(npc.Name)
+	}
+	for _, items := range level.Items {
+		for _, item := range items {
+			seen += len(item.Name)
+		}
+	}
+	for _, trap := range level.Traps {
+		if !trap.Hidden {
+			seen++
+		}
+	}
+	for _, event := range level.Events {
+		seen += len(event)
+	}
+
+	player := level.Player
+	for _, item := range player.Items {
+		seen += len(item.Name)
+	}
+	for _, quest := range player.Quests {
+		for _, objective := range quest.Objectives {
+			seen += len(objective.String())
+		}
+	}
+	if player.Conversation != nil {
+		seen += len(player.AvailableChoices())
+	}
+	if player.Trading != nil {
+		seen += len(player.Trading.Shop.Stock)
+	}
+	return seen
+}
@@ -0,0 +1,33 @@
+package game
+
+import "testing"
+
+// Attacks are recorded for UIs to animate, numbered so they can tell which are new
+func TestAttackRecordsCombat(t *testing.T) {
+	level := &Level{Events: make([]string, 10)}
+	rat := NewRat(Pos{2, 1})
+	spider := NewSpider(Pos{3, 1})
+	damage := level.Attack(&rat.Character, &spider.Character)
+
+	want := Combat{1, Pos{2, 1}, Pos{3, 1}, damage, spider.Hitpoints <= 0}
+	if len(level.Combats) != 1 || level.Combats[0] != want {
+		t.Fatalf("Combats are %+v, want %+v", level.Combats, want)
+	}
+
+	// Only the last few are kept, and the numbers keep counting
+	for i := 0; i < combatHistory+5; i++ {
+		level.Attack(&rat.Character, &spider.Character)
+	}
+	if len(level.Combats) != combatHistory {
+		t.Fatalf("Kept %d combats, want %d", len(level.Combats), combatHistory)
+	}
+	if seq := level.Combats[len(level.Combats)-1].Seq; seq != combatHistory+6 {
+		t.Fatalf("Last combat is %d, want %d", seq, combatHistory+6)
+	}
+
+	spider.Hitpoints = 0
+	level.Attack(&rat.Character, &spider.Character)
+	if !level.Combats[len(level.Combats)-1].Killed {
+		t.Fatal("Killing blow wasn't marked as one")
+	}
+}
@@ -0,0 +1,295 @@
+package game
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"strings"
+)
+
+// NPC is a character that talks instead of fighting
+type NPC struct {
+	Character
+	Dialogue string // Name of the dialogue file to start from
+	Shop     *Shop  // nil if they have nothing to sell
+}
+
+// NewHermit spawns a friendly old man
+func NewHermit(p Pos) *NPC {
+	return &NPC{
+		Character: Character{
+			Entity: Entity{
+				Pos:  p,
+				Name: "Hermit",
+				Rune: 'H',
+			},
+			Hitpoints:    50,
+			MaxHitpoints: 50,
+			SightRange:   10,
+		},
+		Dialogue: "hermit",
+	}
+}
+
+// DialogueNode is a single thing an NPC says, like storyNode in 05_graphs
+type DialogueNode struct {
+	Text    []string // One entry per line of text
+	Choices []*DialogueChoice
+}
+
+// DialogueChoice leads to the next node, if the player meets its conditions
+type DialogueChoice struct {
+	Description string
+	Next        *DialogueNode // nil ends the conversation
+	conditions  []dialogueRule
+	effects     []dialogueRule
+}
+
+// dialogueRule is a verb with a single argument, eg. "has Sword" or "set metHermit"
+type dialogueRule struct {
+	verb string
+	arg  string
+}
+
+// Conversation tracks who the player is talking to, and where they are up to
+type Conversation struct {
+	NPC  *NPC
+	Node *DialogueNode
+}
+
+// AvailableChoices only returns choices the player meets the conditions for
+func (player *Player) AvailableChoices() []*DialogueChoice {
+	choices := make([]*DialogueChoice, 0)
+	if player.Conversation == nil {
+		return choices
+	}
+	for _, choice := range player.Conversation.Node.Choices {
+		if player.meetsConditions(choice) {
+			choices = append(choices, choice)
+		}
+	}
+	return choices
+}
+
+func (player *Player) meetsConditions(choice *DialogueChoice) bool {
+	for _, rule := range choice.conditions {
+		switch rule.verb {
+		case "has":
+			if player.findItem(rule.arg) == nil {
+				return false
+			}
+		case "lacks":
+			if player.findItem(rule.arg) != nil {
+				return false
+			}
+		case "flag":
+			if !player.Flags[rule.arg] {
+				return false
+			}
+		case "not":
+			if player.Flags[rule.arg] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// findItem looks for an item by name in the player's inventory
+func (player *Player) findItem(name string) *Item {
+	for _, item := range player.Items {
+		if item.Name == name {
+			return item
+		}
+	}
+	return nil
+}
+
+// Talk starts a conversation with an NPC next to the player
+func (game *Game) Talk(player *Player) {
+	level := player.level
+	for _, pos := range []Pos{{player.X - 1, player.Y}, {player.X + 1, player.Y}, {player.X, player.Y - 1}, {player.X, player.Y + 1}} {
+		npc, exists := level.NPCs[pos]
+		if !exists {
+			continue
+		}
+		if npc.Shop != nil {
+			game.Trade(player, npc)
+			return
+		}
+		start := game.Dialogues[npc.Dialogue]
+		if start == nil {
+			level.AddEvent(npc.Name + " has nothing to say")
+			return
+		}
+		player.Conversation = &Conversation{npc, start}
+		return
+	}
+	level.AddEvent("There is nobody to talk to")
+}
+
+// Choose picks one of the available choices in the current conversation
+func (game *Game) Choose(player *Player, index int) {
+	level := player.level
+	if player.Conversation == nil {
+		return
+	}
+	choices := player.AvailableChoices()
+	if index < 0 || index >= len(choices) {
+		return
+	}
+	choice := choices[index]
+	for _, rule := range choice.effects {
+		switch rule.verb {
+		case "set":
+			player.Flags[rule.arg] = true
+		case "unset":
+			delete(player.Flags, rule.arg)
+		case "take":
+			item := player.findItem(rule.arg)
+			if item != nil {
+				player.removeItem(item)
+				level.AddEvent(player.Conversation.NPC.Name + " took 1x " + item.Name)
+			}
+		case "give":
+			item := NewItem(rule.arg, player.Pos)
+			if item != nil {
+				player.Items = append(player.Items, item)
+				level.AddEvent(player.Conversation.NPC.Name + " gave 1x " + item.Name)
+			}
+		case "quest":
+			game.StartQuest(player, rule.arg)
+		}
+	}
+	if choice.Next == nil {
+		player.Conversation = nil // Walked away
+		return
+	}
+	player.Conversation.Node = choice.Next
+}
+
+// dialogueQuests lists the quests a conversation can start, following every choice from start
+func dialogueQuests(start *DialogueNode) []string {
+	ids := make([]string, 0)
+	visited := make(map[*DialogueNode]bool)
+	var visit func(node *DialogueNode)
+	visit = func(node *DialogueNode) {
+		if node == nil || visited[node] {
+			return // Conversations loop back on themselves
+		}
+		visited[node] = true
+		for _, choice := range node.Choices {
+			for _, rule := range choice.effects {
+				if rule.verb == "quest" {
+					ids = append(ids, rule.arg)
+				}
+			}
+			visit(choice.Next)
+		}
+	}
+	visit(start)
+	return ids
+}
+
+// removeItem deletes an item from the character's inventory
+func (c *Character) removeItem(itemToRemove *Item) {
+	for i, item := range c.Items {
+		if item == itemToRemove {
+			c.Items = append(c.Items[:i], c.Items[i+1:]...)
+			return
+		}
+	}
+}
+
+// loadDialogues reads every dialogue file into a graph, keyed by file name
+//
+// A dialogue file is a list of nodes. Each node starts with @name, followed by
+// lines of text and then choices:
+//
+//	> Description | next node | has Sword | set metHermit
+//
+// The next node "end" finishes the conversation. Conditions are has, lacks,
+// flag and not. Effects are set, unset, take, give and quest.
+func loadDialogues() map[string]*DialogueNode {
+	dialogues := make(map[string]*DialogueNode)
+	for _, filename := range files.Glob("game/dialogue/*.txt") {
+		name := strings.TrimSuffix(path.Base(filename), ".txt")
+		dialogues[name] = loadDialogue(filename, files.MustRead(filename))
+	}
+	return dialogues
+}
+
+// loadDialogue makes a conversation graph from a dialogue file, and returns its first node.
+// Mistakes panic here, instead of when someone picks the choice.
+func loadDialogue(filename string, data []byte) *DialogueNode {
+	nodes := make(map[string]*DialogueNode)
+	defined := make(map[string]bool)
+	getNode := func(name string) *DialogueNode {
+		// Choices can point forward to nodes we haven't read yet
+		node, exists := nodes[name]
+		if !exists {
+			node = &DialogueNode{}
+			nodes[name] = node
+		}
+		return node
+	}
+
+	var start, current *DialogueNode
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#':
+			continue
+		case line[0] == '@':
+			name := strings.TrimSpace(line[1:])
+			defined[name] = true
+			current = getNode(name)
+			if start == nil {
+				start = current // First node is where every conversation starts
+			}
+		case current == nil:
+			panic("Dialogue text before first node in " + filename)
+		case line[0] == '>':
+			fields := strings.Split(line[1:], "|")
+			if len(fields) < 2 {
+				panic("Dialogue choice without a next node in " + filename)
+			}
+			choice := &DialogueChoice{Description: strings.TrimSpace(fields[0])}
+			next := strings.TrimSpace(fields[1])
+			if next != "end" {
+				choice.Next = getNode(next)
+			}
+			for _, field := range fields[2:] {
+				words := strings.Fields(field)
+				if len(words) != 2 {
+					panic("Invalid dialogue rule " + field + " in " + filename)
+				}
+				rule := dialogueRule{words[0], words[1]}
+				if rule.verb == "give" && NewItem(rule.arg, Pos{}) == nil {
+					panic("No item called " + rule.arg + " to give in " + filename)
+				}
+				switch rule.verb {
+				case "has", "lacks", "flag", "not":
+					choice.conditions = append(choice.conditions, rule)
+				case "set", "unset", "take", "give", "quest":
+					choice.effects = append(choice.effects, rule)
+				default:
+					panic("Invalid dialogue rule " + field + " in " + filename)
+				}
+			}
+			current.Choices = append(current.Choices, choice)
+		default:
+			current.Text = append(current.Text, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		panic(err)
+	}
+	for name := range nodes {
+		if !defined[name] {
+			panic("Dialogue choice goes to " + name + ", but there is no @" + name + " in " + filename)
+		}
+	}
+	return start
+}
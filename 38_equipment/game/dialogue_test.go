@@ -0,0 +1,27 @@
+package game
+
+import "testing"
+
+func TestLoadDialogue(t *testing.T) {
+	start := loadDialogue("test.txt", []byte(`
+@start
+Hello.
+> Bye | end
+> Again | start | give Sword
+> More | more
+
+@more
+That's all.
+> Bye | end | quest ratcatcher
+`))
+	if len(start.Choices) != 3 || start.Choices[1].Next != start || len(start.Choices[2].Next.Text) != 1 {
+		t.Fatalf("Graph came out wrong: %+v", start)
+	}
+
+	wantPanic(t, "there is no @mroe in test.txt", func() {
+		loadDialogue("test.txt", []byte("@start\nHello.\n> More | mroe\n"))
+	})
+	wantPanic(t, "No item called Swrod to give in test.txt", func() {
+		loadDialogue("test.txt", []byte("@start\nHello.\n> Take this | end | give Swrod\n"))
+	})
+}
@@ -0,0 +1,78 @@
+package game
+
+// AutoPickup makes exploring pick up whatever it walks over
+var AutoPickup = true
+
+// Auto-explore is travel that keeps planning routes to the nearest unseen tile
+
+// isFrontier is true for a known tile next to one we haven't seen yet
+func (player *Player) isFrontier(level *Level, pos Pos) bool {
+	seen := player.seenOn(level)
+	dirs := []Pos{{pos.X - 1, pos.Y}, {pos.X + 1, pos.Y}, {pos.X, pos.Y - 1}, {pos.X, pos.Y + 1}}
+	for _, dir := range dirs {
+		if inRange(level, dir) && !seen[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// exploreRoute searches outwards through known tiles for the nearest frontier, and plans a route there
+// With AutoPickup, items we know about count as somewhere to go too
+func (player *Player) exploreRoute() []Pos {
+	level := player.level
+	nowhere := Pos{-1, -1} // Never the goal, so stairs and portals are always avoided
+	frontier := make([]Pos, 0, 8)
+	frontier = append(frontier, player.Pos)
+	visited := make(map[Pos]bool)
+	visited[player.Pos] = true
+
+	for len(frontier) > 0 {
+		current := frontier[0]
+		frontier = frontier[1:]
+		wanted := player.isFrontier(level, current) || (AutoPickup && len(level.Items[current]) > 0)
+		if current != player.Pos && wanted {
+			return player.planRoute(current)
+		}
+		for _, next := range player.knownNeighbors(level, current, nowhere) {
+			if !visited[next] {
+				frontier = append(frontier, next)
+				visited[next] = true
+			}
+		}
+	}
+	return nil
+}
+
+func (game *Game) startExplore(player *Player) {
+	level := player.level
+	if monster := player.monsterInView(); monster != nil {
+		level.AddEvent("Not with a " + monster.Name + " nearby")
+		return
+	}
+	route := player.exploreRoute()
+	if route == nil {
+		level.AddEvent("Nothing left to explore here")
+		return
+	}
+	player.setRoute(route)
+	player.exploring = true
+	game.travelStep(player)
+}
+
+// exploreStep runs after each step while exploring, to pick things up and find somewhere new to go
+func (game *Game) exploreStep(player *Player) {
+	level := player.level
+	if AutoPickup && len(level.Items[player.Pos]) > 0 {
+		game.takeAll(player)
+		player.routeEvents = level.EventPos // Picking things up is no reason to stop
+	}
+	if len(player.Route) > 0 {
+		return
+	}
+	player.Route = player.exploreRoute()
+	if player.Route == nil {
+		player.stopTravel()
+		level.AddEvent("Nothing left to explore here")
+	}
+}
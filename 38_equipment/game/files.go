@@ -0,0 +1,13 @@
+package game
+
+import (
+	"embed"
+
+	"github.com/maxproske/games-with-go/38_equipment/assets"
+)
+
+//go:embed maps dialogue *.txt
+var gameFiles embed.FS
+
+// files are the maps and data the game loads, built in unless -assets overrides them
+var files = assets.New(gameFiles, "game")
@@ -0,0 +1,136 @@
+package game
+
+import (
+	"errors"
+	"sort"
+)
+
+// Frontend shows the game to one player and turns what they do into inputs.
+// Most frontends embed Channels for everything but Run.
+type Frontend interface {
+	// Run plays until the game is done with the frontend, then returns
+	Run()
+	// Levels delivers the latest level to draw. Levels the frontend hasn't read yet are
+	// replaced, not queued. The game closes it once it is done with the frontend, eg.
+	// after Close or when the player's hero dies.
+	Levels() <-chan *Level
+	// Send hands the game something the player did
+	Send(input *Input)
+	// Close leaves the game, everyone else keeps playing
+	Close()
+}
+
+// Channels connects a frontend to one player in a game, or to anything standing in
+// for a game like netplay.Client
+type Channels struct {
+	inputChan chan *Input
+	levelChan chan *Level
+}
+
+// NewChannels binds a frontend to a game's input channel and one of its level channels
+func NewChannels(inputChan chan *Input, levelChan chan *Level) Channels {
+	return Channels{inputChan, levelChan}
+}
+
+// Channels binds a frontend to Players[i]
+func (game *Game) Channels(i int) Channels {
+	return Channels{game.InputChan, game.LevelChans[i]}
+}
+
+// Levels is where levels to draw arrive
+func (c Channels) Levels() <-chan *Level {
+	return c.levelChan
+}
+
+// Send tells the game which player the input is from, and waits for the game to take it
+func (c Channels) Send(input *Input) {
+	c.inputChan <- c.From(input)
+}
+
+// Close sends CloseWindow, the game closes Levels once it has let go of the player
+func (c Channels) Close() {
+	c.Send(&Input{Typ: CloseWindow})
+}
+
+// From fills in which player an input is from, for frontends that send it themselves
+func (c Channels) From(input *Input) *Input {
+	input.LevelChannel = c.levelChan
+	return input
+}
+
+// Inputs is the game's input channel, for frontends that send with select so they can give up
+func (c Channels) Inputs() chan<- *Input {
+	return c.inputChan
+}
+
+// FrontendMaker binds a new frontend to one player
+type FrontendMaker func(channels Channels) Frontend
+
+var frontends = make(map[string]FrontendMaker)
+
+// RegisterFrontend makes a frontend available by name, usually from the init of its package
+func RegisterFrontend(name string, maker FrontendMaker) {
+	if _, exists := frontends[name]; exists {
+		panic("Frontend registered twice: " + name)
+	}
+	frontends[name] = maker
+}
+
+// NewFrontend makes a registered frontend for one player
+func NewFrontend(name string, channels Channels) (Frontend, error) {
+	maker, exists := frontends[name]
+	if !exists {
+		return nil, errors.New("game: no frontend called " + name)
+	}
+	return maker(channels), nil
+}
+
+// FrontendNames lists every registered frontend, in order
+func FrontendNames() []string {
+	names := make([]string, 0, len(frontends))
+	for name := range frontends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterFrontend("headless", func(channels Channels) Frontend {
+		return NewRecorder(channels)
+	})
+}
+
+// Recorder is a frontend that draws nothing. It keeps every level it is sent,
+// and answers each one with the next input from its script, then leaves.
+// Handy for driving the game from tests without a window.
+type Recorder struct {
+	Channels
+	Recorded []*Level
+	Script   []*Input
+}
+
+// NewRecorder makes a recorder that will play the script
+func NewRecorder(channels Channels, script ...*Input) *Recorder {
+	return &Recorder{Channels: channels, Script: script}
+}
+
+// Run records levels until the game closes the channel
+func (r *Recorder) Run() {
+	closing := false
+	for level := range r.Levels() {
+		r.Recorded = append(r.Recorded, level)
+		if closing {
+			continue
+		}
+		if len(r.Script) == 0 {
+			closing = true
+			r.Close() // Close the window once the script runs out
+			continue
+		}
+		input := *r.Script[0] // Copy, so the script can be played more than once
+		r.Script = r.Script[1:]
+		closing = input.Typ == CloseWindow
+		r.Send(&input)
+	}
+}
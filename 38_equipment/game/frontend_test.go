@@ -0,0 +1,38 @@
+package game
+
+import "testing"
+
+func TestRecorder(t *testing.T) {
+	inTempDir(t)
+	g := NewGame(1)
+	r := NewRecorder(g.Channels(0), &Input{Typ: Search}, &Input{Typ: Search})
+	done := make(chan struct{})
+	go func() {
+		r.Run()
+		close(done)
+	}()
+	g.Run() // Returns once the recorder runs out of script and closes its window
+	<-done
+
+	// The first level, one for each search, and none after closing
+	if len(r.Recorded) != 3 {
+		t.Fatalf("Recorded %d levels, want 3", len(r.Recorded))
+	}
+	if turns := r.Recorded[2].Player.Stats.Turns; turns != 2 {
+		t.Fatalf("Last level is after %d turns, want 2", turns)
+	}
+}
+
+func TestNewFrontend(t *testing.T) {
+	g := NewGame(1)
+	ui, err := NewFrontend("headless", g.Channels(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ui.(*Recorder); !ok {
+		t.Fatalf("headless made a %T, want a *Recorder", ui)
+	}
+	if _, err := NewFrontend("nope", g.Channels(0)); err == nil {
+		t.Fatal("Made a frontend that was never registered")
+	}
+}
@@ -2,35 +2,63 @@ package game
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/csv"
 	"fmt"
 	"math"
-	"os"
-	"path/filepath"
+	"path"
 	"strconv"
 	"strings"
+
+	"github.com/maxproske/games-with-go/38_equipment/assets"
 )
 
 // Game contains channels for game and UI threads
 type Game struct {
-	LevelChans   []chan *Level // Send level state to multiple UIs
-	InputChan    chan *Input   // Receieve input from multiple UIs
-	Levels       map[string]*Level
-	CurrentLevel *Level
+	LevelChans []chan *Level // Send level state to multiple UIs
+	InputChan  chan *Input   // Receieve input from multiple UIs
+	Levels     map[string]*Level
+	Players    []*Player                // Players[i] is controlled by the UI on LevelChans[i]
+	Turn       int                      // How many inputs have been processed
+	Dialogues  map[string]*DialogueNode // Conversation graphs NPCs start from
+	QuestDefs  map[string]*Quest        // Quests the player can be given
+
+	Achievements []*Achievement // Every achievement there is, in the order to list them
+
+	questTriggers []questTrigger
+	feeds         map[chan *Level]*feed // UIs that asked for updates instead of levels
+	scripts       []*script             // Triggers from scripts.txt
+	scriptDepth   int                   // How many scripts are running inside each other
+	mapChanges    <-chan string         // Maps edited while playing, see reload.go
 }
 
 // NewGame needs to know how many channels to take in
 func NewGame(numWindows int) *Game {
 	levelChans := make([]chan *Level, numWindows) // 1 level channel for each window
 	for i := range levelChans {
-		levelChans[i] = make(chan *Level)
+		levelChans[i] = make(chan *Level, 1) // Only ever holds the latest level, so slow UIs skip ahead
 	}
 	inputChan := make(chan *Input)
 	levels := loadLevels()
 
-	game := &Game{levelChans, inputChan, levels, nil}
-	game.loadWorldFile()            // Load world file
-	game.CurrentLevel.lineOfSight() // Draw visible tiles without moving
+	questDefs, questTriggers := loadQuests()
+
+	game := &Game{
+		LevelChans:    levelChans,
+		InputChan:     inputChan,
+		Levels:        levels,
+		Dialogues:     loadDialogues(),
+		QuestDefs:     questDefs,
+		Achievements:  loadAchievements(),
+		questTriggers: questTriggers,
+		feeds:         make(map[chan *Level]*feed),
+		scripts:       loadScripts(),
+		mapChanges:    assets.Watch("game/maps/*.map"),
+	}
+	start := game.loadWorldFile()        // Load world file
+	game.checkQuests()                   // Catch quests that can never be finished
+	game.assignDepths(start)             // Work out how deep each level is from its stairs
+	game.spawnPlayers(start, numWindows) // Give every window its own hero
 
 	return game
 }
@@ -63,13 +91,41 @@ const (
 	EquipItem
 	// Search input type
 	Search
+	// Descend takes the down stair the player is standing on
+	Descend
+	// Ascend takes the up stair the player is standing on
+	Ascend
+	// Talk starts a conversation with an adjacent NPC
+	Talk
+	// Choose picks a dialogue choice
+	Choose
+	// Buy an item from the merchant the player is trading with
+	Buy
+	// Sell an item to the merchant the player is trading with
+	Sell
+	// LeaveShop stops trading
+	LeaveShop
+	// SaveGame writes the game to the save file
+	SaveGame
+	// LoadGame replaces the game with the save file
+	LoadGame
+	// Travel walks to Input.Pos one turn at a time
+	Travel
+	// TravelStairs walks to the nearest stair the player knows about
+	TravelStairs
+	// KeepTraveling takes the next step of a route, sent by UIs while Player.Traveling
+	KeepTraveling
+	// Explore travels to the nearest unseen places until there are none left
+	Explore
 )
 
 // Input ...
 type Input struct {
 	Typ          InputType
-	Item         *Item // Item will be the data, not the position of a click
-	LevelChannel chan *Level
+	Item         *Item       // Item will be the data, not the position of a click
+	Choice       int         // Index into the player's available dialogue choices
+	Pos          Pos         // Tile to travel to
+	LevelChannel chan *Level // Which UI the input came from, and so which player it moves
 }
 
 // Tile enum is just an alias for a rune (a character in Go)
@@ -89,6 +145,8 @@ const (
 	ClosedDoor = '|'
 	// OpenDoor represented by a character
 	OpenDoor = '/'
+	// SecretDoor looks like a wall until it is found
+	SecretDoor = '+'
 	// UpStair represented bu a character
 	UpStair = 'u'
 	// DownStair represented by a character
@@ -120,16 +178,38 @@ type Entity struct {
 // Player ...
 type Player struct {
 	Character
+	Flags        map[string]bool // Set by dialogue to remember what has happened
+	Conversation *Conversation   // nil when not talking to anyone
+	Trading      *NPC            // Merchant whose shop is open
+	Gold         int
+	Quests       []*Quest
+	Light        *Light // Carried so the player can see, shared with snapshots like items
+	Stats        Stats
+	Achievements []string // IDs of the achievements the player has earned
+	Route        []Pos    // Steps left to travel, see travel.go. Sent to UIs so they keep asking for the next
+
+	level   *Level                  // Level the player is on
+	visible map[Pos]bool            // What the player can see right now
+	seen    map[string]map[Pos]bool // Level name to tiles the player has seen before
+	ended   string                  // Why the run is over, empty while it isn't
+	won     bool
+
+	routeLevel  *Level // Level the route is on
+	routeEvents int    // EventPos when the route was planned, so we stop when something happens
+	routeHP     int    // Hitpoints when the route was planned, so we stop when hurt
+	exploring   bool   // Plan a new route to somewhere unseen when this one runs out
 }
 
 // Character ...
 type Character struct {
 	Entity
 	Hitpoints    int
+	MaxHitpoints int
 	Strength     int
 	Speed        float64
 	ActionPoints float64
 	SightRange   int
+	Perception   int // Out of 20, chance of finding hidden things
 	Items        []*Item
 	Helmet       *Item
 	Weapon       *Item
@@ -147,19 +227,32 @@ const (
 	Portal
 	PickUp
 	Drop
+	TrapTriggered
 )
 
 // Level holds the 2D array that represents the map
 type Level struct {
+	Name      string
+	Depth     int // Used to scale difficulty of generated levels
 	Map       [][]Tile
-	Player    *Player
+	Player    *Player          // Whoever this copy of the level was sent to, nil in the game's own copy
+	Players   []*Player        // Everyone on the level
 	Monsters  map[Pos]*Monster // Pos as key, get back monster
-	Items     map[Pos][]*Item  // Allow multiple items per tile
+	NPCs      map[Pos]*NPC
+	Items     map[Pos][]*Item // Allow multiple items per tile
 	Portals   map[Pos]*LevelPos
+	Traps     map[Pos]*Trap
+	Lights    map[Pos]*Light // Torches, only ever swapped whole by a map reload so snapshots share them
 	Events    []string
 	EventPos  int
+	Combats   []Combat     // The last few attacks, oldest first
 	Debug     map[Pos]bool // Map x/y positions to true/false
 	LastEvent GameEvent    // Events not visible to the player
+	LastTurn  int          // Turn the player last left this level
+
+	monsterCap int      // Respawns stop once the level is back to this many monsters
+	spawn      Pos      // Where the '@' was in the map file
+	loaded     [][]Tile // The map as the file had it, so a reload can tell which tiles were edited
 }
 
 // DropItem ...
@@ -195,8 +288,20 @@ func (level *Level) MoveItem(itemToMove *Item, character *Character) {
 	panic("Tried to move an item we're not on top of")
 }
 
-// Attack engages two attackables
-func (level *Level) Attack(c1, c2 *Character) {
+// Combat is one attack, so UIs can show it without reading the event log
+type Combat struct {
+	Seq      int // Counts up on each level, so UIs can tell which attacks they haven't shown
+	Attacker Pos
+	Victim   Pos
+	Damage   int
+	Killed   bool
+}
+
+// How many attacks a level remembers, more than can happen between two levels a UI is sent
+const combatHistory = 32
+
+// Attack engages two attackables, and returns the damage done
+func (level *Level) Attack(c1, c2 *Character) int {
 	// a1 attacking a2 first
 	c1.ActionPoints--
 	c1AttackPower := c1.Strength
@@ -220,6 +325,20 @@ func (level *Level) Attack(c1, c2 *Character) {
 	} else {
 		level.AddEvent(c1.Name + " Killed " + c2.Name)
 	}
+	level.addCombat(Combat{Attacker: c1.Pos, Victim: c2.Pos, Damage: damage, Killed: c2.Hitpoints <= 0})
+	return damage
+}
+
+// addCombat numbers an attack and remembers it, forgetting the oldest
+func (level *Level) addCombat(combat Combat) {
+	combat.Seq = 1
+	if n := len(level.Combats); n > 0 {
+		combat.Seq = level.Combats[n-1].Seq + 1
+	}
+	level.Combats = append(level.Combats, combat)
+	if len(level.Combats) > combatHistory {
+		level.Combats = level.Combats[1:]
+	}
 }
 
 // AddEvent handles events list
@@ -232,9 +351,9 @@ func (level *Level) AddEvent(event string) {
 
 }
 
-func (level *Level) lineOfSight() {
-	pos := level.Player.Pos
-	dist := level.Player.SightRange // Radius
+func (level *Level) lineOfSight(player *Player) {
+	pos := player.Pos
+	dist := player.SightRange // Radius
 	// Iterate over square the size of player sight range
 	for y := pos.Y - dist; y <= pos.Y+dist; y++ {
 		for x := pos.X - dist; x <= pos.X+dist; x++ {
@@ -242,14 +361,15 @@ func (level *Level) lineOfSight() {
 			yDelta := pos.Y - y
 			d := math.Sqrt(float64(xDelta*xDelta + yDelta*yDelta))
 			if d <= float64(dist) {
-				level.bresenham(pos, Pos{x, y})
+				level.bresenham(player, pos, Pos{x, y})
 			}
 		}
 	}
 }
 
 // Draw a circle around the player and draw a line to each endpoint
-func (level *Level) bresenham(start Pos, end Pos) {
+func (level *Level) bresenham(player *Player, start Pos, end Pos) {
+	seen := player.seenOn(level)
 	steep := math.Abs(float64(end.Y-start.Y)) > math.Abs(float64(end.X-start.X)) // Is the line steep or not?
 	// Swap the x and y for start and end
 	if steep {
@@ -277,8 +397,8 @@ func (level *Level) bresenham(start Pos, end Pos) {
 			} else {
 				pos = Pos{x, y}
 			}
-			level.Map[pos.Y][pos.X].Visible = true
-			level.Map[pos.Y][pos.X].Seen = true // Stay true
+			player.visible[pos] = true
+			seen[pos] = true // Stay true
 			if !canSeeThrough(level, pos) {
 				return
 			}
@@ -297,8 +417,8 @@ func (level *Level) bresenham(start Pos, end Pos) {
 			} else {
 				pos = Pos{x, y}
 			}
-			level.Map[pos.Y][pos.X].Visible = true
-			level.Map[pos.Y][pos.X].Seen = true // Stay true
+			player.visible[pos] = true
+			seen[pos] = true // Stay true
 			if !canSeeThrough(level, pos) {
 				return
 			}
@@ -311,12 +431,10 @@ func (level *Level) bresenham(start Pos, end Pos) {
 	}
 }
 
-func (game *Game) loadWorldFile() {
-	file, err := os.Open("game/maps/world.txt")
-	if err != nil {
-		panic(err)
-	}
-	csvReader := csv.NewReader(file)
+// loadWorldFile links up portals, and returns the level players start on
+func (game *Game) loadWorldFile() *Level {
+	var start *Level
+	csvReader := csv.NewReader(bytes.NewReader(files.MustRead("game/maps/world.txt")))
 	csvReader.FieldsPerRecord = -1 // Don't enforce each row to have same num columns
 	csvReader.TrimLeadingSpace = true
 	rows, err := csvReader.ReadAll() // Our files are not going to be very big, so we can use ReadAll instead of Read
@@ -326,8 +444,8 @@ func (game *Game) loadWorldFile() {
 	for rowIndex, row := range rows {
 		// Set current level
 		if rowIndex == 0 {
-			game.CurrentLevel = game.Levels[row[0]] // Get the first item from the first row, and set the level
-			if game.CurrentLevel == nil {
+			start = game.Levels[row[0]] // Get the first item from the first row, and set the level
+			if start == nil {
 				fmt.Println("Couldn't find current level name in world file.")
 				panic(nil)
 			}
@@ -366,127 +484,130 @@ func (game *Game) loadWorldFile() {
 
 		levelWithPortal.Portals[pos] = &LevelPos{levelToTeleportTo, posToTeleportTo} // Our position to teleport to
 	}
+	return start
 }
 
-// loadLevels opens and prints a map
+// loadLevels opens every map
 func loadLevels() map[string]*Level {
-	// Make player
-	player := &Player{} // Player used to not be a pointer
-	player.Strength = 5
-	player.Hitpoints = 100
-	player.Name = "GoMan"
-	player.Rune = '@'
-	player.Speed = 1.0
-	player.ActionPoints = 0
-	player.SightRange = 7
 	levels := make(map[string]*Level)
-	// Load level
-	filenames, err := filepath.Glob("game/maps/*.map")
-	if err != nil {
-		panic(err)
+	for _, filename := range files.Glob("game/maps/*.map") {
+		levelName := strings.TrimSuffix(path.Base(filename), ".map")
+		levels[levelName] = loadLevel(levelName, files.MustRead(filename))
 	}
-	for _, filename := range filenames {
-		extIndex := strings.LastIndex(filename, ".map")
-		lastSlashIndex := strings.LastIndex(filename, "\\")
-		levelName := filename[lastSlashIndex+1 : extIndex]
-		// Open file
-		file, err := os.Open(filename)
-		if err != nil {
-			panic(err)
+	return levels
+}
+
+// loadLevel makes a level from a map file
+func loadLevel(levelName string, data []byte) *Level {
+	// Read from scanner
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	levelLines := make([]string, 0)
+	longestRow := 0 // Map width (length)
+	index := 0      // Map height (rows)
+
+	for scanner.Scan() {
+		levelLines = append(levelLines, scanner.Text()) // String for each row of our map
+		// Keep track of longest line
+		if len(levelLines[index]) > longestRow {
+			longestRow = len(levelLines[index])
 		}
-		defer file.Close()
+		index++
+	}
 
-		// Read from scanner
-		scanner := bufio.NewScanner(file) // *File satisfies io.Reader interface
-		levelLines := make([]string, 0)
-		longestRow := 0 // Map width (length)
-		index := 0      // Map height (rows)
+	level := newLevel(levelName)
+	level.Map = make([][]Tile, len(levelLines))
 
-		for scanner.Scan() {
-			levelLines = append(levelLines, scanner.Text()) // String for each row of our map
-			// Keep track of longest line
-			if len(levelLines[index]) > longestRow {
-				longestRow = len(levelLines[index])
-			}
-			index++
-		}
-
-		level := &Level{}
-		level.Debug = make(map[Pos]bool)
-		level.Events = make([]string, 10)
-		level.Player = player
-		level.Map = make([][]Tile, len(levelLines))
-		level.Monsters = make(map[Pos]*Monster)
-		level.Items = make(map[Pos][]*Item)
-		level.Portals = make(map[Pos]*LevelPos)
-
-		for i := range level.Map {
-			level.Map[i] = make([]Tile, longestRow) // Make each row the same length of the longest row (non-jagged slice)
-		}
-
-		for y := 0; y < len(level.Map); y++ {
-			line := levelLines[y]
-			for x, c := range line {
-				pos := Pos{x, y}
-				var t Tile
-				t.OverlayRune = Blank // Most things will not have an overlay rune
-				switch c {
-				case ' ', '\t', '\n', '\r':
-					t.Rune = Blank
-				case '#':
-					t.Rune = StoneWall
-				case '|':
-					t.OverlayRune = ClosedDoor
-					t.Rune = Pending
-				case '/':
-					t.Rune = OpenDoor
-				case 'u':
-					t.OverlayRune = UpStair
-					t.Rune = Pending
-				case 'd':
-					t.OverlayRune = DownStair
-					t.Rune = Pending
-				case 's':
-					level.Items[pos] = append(level.Items[pos], NewSword(pos)) // Append item to slice of items, follow monster template
-					level.Items[pos] = append(level.Items[pos], NewHelmet(pos))
-					t.Rune = Pending
-				case 'h':
-					level.Items[pos] = append(level.Items[pos], NewHelmet(pos))
-					t.Rune = Pending
-				case '.':
-					t.Rune = DirtFloor
-				case '@':
-					level.Player.X = x // Set player X,Y
-					level.Player.Y = y
-					t.Rune = Pending // Be a placeholder
-				case 'R':
-					// Rat
-					level.Monsters[pos] = NewRat(pos)
-					t.Rune = Pending
-				case 'S':
-					// Spider
-					level.Monsters[pos] = NewSpider(pos)
-					t.Rune = Pending
-				default:
-					panic("Invalid character in map!")
-				}
-				level.Map[y][x] = t
+	for i := range level.Map {
+		level.Map[i] = make([]Tile, longestRow) // Make each row the same length of the longest row (non-jagged slice)
+	}
+
+	for y := 0; y < len(level.Map); y++ {
+		line := levelLines[y]
+		for x, c := range line {
+			pos := Pos{x, y}
+			var t Tile
+			t.OverlayRune = Blank // Most things will not have an overlay rune
+			switch c {
+			case ' ', '\t', '\n', '\r':
+				t.Rune = Blank
+			case '#':
+				t.Rune = StoneWall
+			case '|':
+				t.OverlayRune = ClosedDoor
+				t.Rune = Pending
+			case '/':
+				t.Rune = OpenDoor
+			case '+':
+				t.OverlayRune = SecretDoor
+				t.Rune = Pending
+			case '^':
+				level.Traps[pos] = NewSpikeTrap(pos)
+				t.Rune = Pending
+			case '~':
+				level.Traps[pos] = NewTeleportTrap(pos)
+				t.Rune = Pending
+			case '!':
+				level.Traps[pos] = NewAlarmTrap(pos)
+				t.Rune = Pending
+			case 'u':
+				t.OverlayRune = UpStair
+				t.Rune = Pending
+			case 'd':
+				t.OverlayRune = DownStair
+				t.Rune = Pending
+			case 's':
+				level.Items[pos] = append(level.Items[pos], NewSword(pos)) // Append item to slice of items, follow monster template
+				level.Items[pos] = append(level.Items[pos], NewHelmet(pos))
+				t.Rune = Pending
+			case 'h':
+				level.Items[pos] = append(level.Items[pos], NewHelmet(pos))
+				t.Rune = Pending
+			case '.':
+				t.Rune = DirtFloor
+			case Torch:
+				level.Lights[pos] = NewTorch()
+				t.Rune = Pending
+			case '@':
+				level.spawn = pos // Players start here
+				t.Rune = Pending  // Be a placeholder
+			case 'R':
+				// Rat
+				level.Monsters[pos] = NewRat(pos)
+				t.Rune = Pending
+			case 'S':
+				// Spider
+				level.Monsters[pos] = NewSpider(pos)
+				t.Rune = Pending
+			case 'H':
+				// Hermit
+				level.NPCs[pos] = NewHermit(pos)
+				t.Rune = Pending
+			case 'M':
+				// Merchant
+				level.NPCs[pos] = NewMerchant(pos)
+				t.Rune = Pending
+			default:
+				panic("Invalid character in map!")
 			}
+			level.Map[y][x] = t
 		}
+	}
 
-		// Go over the map again
-		// TODO(max): Use bfs to find first floor tile
-		for y, row := range level.Map {
-			for x, tile := range row {
-				if tile.Rune == Pending {
-					level.Map[y][x].Rune = level.bfsFloor(Pos{x, y}) // Use bfs to find the nearest floor tile, and send it to it
-				}
+	// Go over the map again
+	// TODO(max): Use bfs to find first floor tile
+	for y, row := range level.Map {
+		for x, tile := range row {
+			if tile.Rune == Pending {
+				level.Map[y][x].Rune = level.bfsFloor(Pos{x, y}) // Use bfs to find the nearest floor tile, and send it to it
 			}
 		}
-		// Append the current level to our level slice
-		levels[levelName] = level
 	}
-	return levels
+	level.monsterCap = len(level.Monsters)
+	level.loaded = make([][]Tile, len(level.Map))
+	for y, row := range level.Map {
+		level.loaded[y] = append([]Tile{}, row...)
+	}
+	return level
 }
 
 // Check if x,y is inbounds
@@ -503,7 +624,7 @@ func canWalk(level *Level, pos Pos) bool {
 			return false
 		}
 		switch t.OverlayRune {
-		case ClosedDoor:
+		case ClosedDoor, SecretDoor:
 			return false
 		}
 		// Check to see if a monster is in the way
@@ -511,6 +632,10 @@ func canWalk(level *Level, pos Pos) bool {
 		if exists {
 			return false
 		}
+		_, exists = level.NPCs[pos]
+		if exists {
+			return false
+		}
 		return true
 	}
 	return false
@@ -526,7 +651,7 @@ func canSeeThrough(level *Level, pos Pos) bool {
 			return false
 		}
 		switch t.OverlayRune {
-		case ClosedDoor:
+		case ClosedDoor, SecretDoor:
 			return false
 		default:
 			return true
@@ -541,55 +666,126 @@ func checkDoor(level *Level, pos Pos) {
 	if t.OverlayRune == ClosedDoor {
 		level.Map[pos.Y][pos.X].OverlayRune = OpenDoor // Player has opened a door
 		level.LastEvent = OpenDoor
-		level.lineOfSight() // Check line of sight without moving a tile
+		level.updateVisibility() // Check line of sight without moving a tile
 	}
 }
 
 // Move moves the player unless a monster exists in that location
-func (game *Game) Move(to Pos) {
-	level := game.CurrentLevel
-	player := level.Player
+func (game *Game) Move(player *Player, to Pos) {
+	level := player.level
 
 	// Check position we are moving to for portals
 	levelAndPos := level.Portals[to]
+	if levelAndPos == nil && isStair(level, to) {
+		levelAndPos = game.connectStairs(level, to) // Generate the next level the first time a stair is used
+	}
 	if levelAndPos != nil {
-		fmt.Println("in portal!")
-		game.CurrentLevel = levelAndPos.Level
-		game.CurrentLevel.Player.Pos = levelAndPos.Pos
-		game.CurrentLevel.lineOfSight()
+		game.travel(player, to, levelAndPos)
 	} else {
 		player.Pos = to // Player has moved
+		player.Stats.Steps++
 		level.LastEvent = Move
-		// Draw line of sight
-		for y, row := range level.Map {
-			for x := range row {
-				level.Map[y][x].Visible = false
-			}
+		level.resetVisibility(player)
+		game.triggerTrap(player, to)
+		game.questMove(player)
+		game.runScripts(player, "step", "", player.Pos)
+	}
+}
+
+// travel moves the player through a portal or stair onto another level
+func (game *Game) travel(player *Player, from Pos, levelAndPos *LevelPos) {
+	prevLevel := player.level
+	prevLevel.removePlayer(player)
+	if len(prevLevel.Players) == 0 {
+		prevLevel.LastTurn = game.Turn // Nobody is left to keep it going
+	}
+	level := levelAndPos.Level
+	if len(level.Players) == 0 {
+		level.simulate(game.Turn - level.LastTurn) // Catch up on everything we missed
+	}
+	player.Pos = levelAndPos.Pos
+	if level.playerAt(player.Pos) != nil {
+		// Someone is still standing on the way in, so step off to the side
+		if pos, ok := level.freeTileNear(player.Pos); ok {
+			player.Pos = pos
 		}
-		level.lineOfSight()
 	}
+	level.addPlayer(player)
+	player.Stats.visit(level)
+	level.followThroughPortal(prevLevel, from, player)
+	level.LastEvent = Portal
+	level.resetVisibility(player)
+	level.AddEvent(player.Name + " entered " + level.Name + " (depth " + strconv.Itoa(level.Depth) + ")")
+	game.questMove(player)
+	game.runScripts(player, "step", "", player.Pos)
+}
+
+// takeStairs uses the stair the player is standing on, if it goes the right way
+func (game *Game) takeStairs(player *Player, stair rune) {
+	level := player.level
+	pos := player.Pos
+	if level.Map[pos.Y][pos.X].OverlayRune != stair {
+		level.AddEvent("There are no stairs here")
+		return
+	}
+	levelAndPos := level.Portals[pos]
+	if levelAndPos == nil {
+		levelAndPos = game.connectStairs(level, pos)
+	}
+	if levelAndPos == nil {
+		level.AddEvent("These stairs lead out of the dungeon")
+		return
+	}
+	game.travel(player, pos, levelAndPos)
+}
+
+func isStair(level *Level, pos Pos) bool {
+	switch level.Map[pos.Y][pos.X].OverlayRune {
+	case UpStair, DownStair:
+		return true
+	}
+	return false
+}
+
+// resetVisibility redraws a player's line of sight from scratch
+func (level *Level) resetVisibility(player *Player) {
+	player.visible = make(map[Pos]bool)
+	level.lineOfSight(player)
 }
 
 // Handle decisions about player movement
-func (game *Game) resolveMovement(pos Pos) {
-	level := game.CurrentLevel
+func (game *Game) resolveMovement(player *Player, pos Pos) {
+	level := player.level
 	monster, exists := level.Monsters[pos]
 	if exists {
-		level.Attack(&level.Player.Character, &monster.Character) // Attacked
+		player.Stats.DamageDealt += level.Attack(&player.Character, &monster.Character) // Attacked
 		level.LastEvent = Attack
 		if monster.Hitpoints <= 0 {
 			monster.Kill(level)
+			player.Stats.Kills[monster.Name]++
+			game.questKill(player, monster)
+			game.runScripts(player, "kill", monster.Name, monster.Pos)
 		}
-		if level.Player.Hitpoints <= 0 {
-			panic("ded")
-		}
-	} else if canWalk(level, pos) {
-		game.Move(pos)
+	} else if canWalk(level, pos) && level.playerAt(pos) == nil {
+		game.Move(player, pos)
 	} else {
 		checkDoor(level, pos)
 	}
 }
 
+// takeAll picks up everything on the player's tile
+func (game *Game) takeAll(p *Player) {
+	level := p.level
+	items := append([]*Item{}, level.Items[p.Pos]...) // MoveItem shrinks the slice we're looping over
+	for _, item := range items {
+		level.MoveItem(item, &p.Character)
+		p.Stats.ItemsPickedUp++
+		game.questPickUp(p, item)
+		game.runScripts(p, "pickup", item.Name, p.Pos)
+	}
+	level.LastEvent = PickUp
+}
+
 func equip(c *Character, itemToEquip *Item) {
 	// Verify character has the item they are trying to equip
 	for i, item := range c.Items {
@@ -608,46 +804,84 @@ func equip(c *Character, itemToEquip *Item) {
 
 // Returning a *Level is slow
 func (game *Game) handleInput(input *Input) {
-	level := game.CurrentLevel
-	p := level.Player
+	p := game.playerFor(input.LevelChannel)
+	if p == nil {
+		return // The window already closed, eg. because its hero died
+	}
+	if input.Typ == CloseWindow {
+		close(input.LevelChannel) // Close level input game from
+		game.closeFeed(input.LevelChannel)
+		game.removePlayerFor(input.LevelChannel)
+		return
+	}
+	level := p.level
+	p.Stats.Turns++
+	if input.Typ != KeepTraveling {
+		p.stopTravel() // Doing anything else cancels travel
+	}
+	// Walking away ends the conversation
+	switch input.Typ {
+	case Up, Down, Left, Right:
+		p.Conversation = nil
+		p.Trading = nil
+	}
+
 	// Check if the place the player is going to is available
 	switch input.Typ {
 	case Up:
 		newPos := Pos{p.X, p.Y - 1}
-		game.resolveMovement(newPos)
+		game.resolveMovement(p, newPos)
 	case Down:
 		newPos := Pos{p.X, p.Y + 1}
-		game.resolveMovement(newPos)
+		game.resolveMovement(p, newPos)
 	case Left:
 		newPos := Pos{p.X - 1, p.Y}
-		game.resolveMovement(newPos)
+		game.resolveMovement(p, newPos)
 	case Right:
 		newPos := Pos{p.X + 1, p.Y}
-		game.resolveMovement(newPos)
+		game.resolveMovement(p, newPos)
 	case TakeItem:
 		level.MoveItem(input.Item, &p.Character)
+		p.Stats.ItemsPickedUp++
+		game.questPickUp(p, input.Item)
+		game.runScripts(p, "pickup", input.Item.Name, p.Pos)
 		level.LastEvent = PickUp
 	case DropItem:
-		level.DropItem(input.Item, &level.Player.Character)
+		level.DropItem(input.Item, &p.Character)
 		level.LastEvent = Drop // Update activity log
 	case TakeAll:
-		for _, item := range level.Items[p.Pos] {
-			level.MoveItem(item, &p.Character)
-		}
-		level.LastEvent = PickUp
+		game.takeAll(p)
 	case EquipItem:
-		equip(&level.Player.Character, input.Item)
-	case CloseWindow:
-		close(input.LevelChannel) // Close level input game from
-		chanIndex := 0
-		for i, c := range game.LevelChans {
-			if c == input.LevelChannel {
-				chanIndex = i
-				break
-			}
-		}
-		// Remove an item from a slice
-		game.LevelChans = append(game.LevelChans[:chanIndex], game.LevelChans[chanIndex+1:]...)
+		equip(&p.Character, input.Item)
+		game.runScripts(p, "equip", input.Item.Name, p.Pos)
+	case Search:
+		level.Search(&p.Character)
+	case Descend:
+		game.takeStairs(p, DownStair)
+	case Ascend:
+		game.takeStairs(p, UpStair)
+	case Talk:
+		game.Talk(p)
+	case Choose:
+		game.Choose(p, input.Choice)
+	case Buy:
+		game.Buy(p, input.Item)
+	case Sell:
+		game.Sell(p, input.Item)
+	case LeaveShop:
+		p.Trading = nil
+	case SaveGame:
+		game.Save(p)
+	case LoadGame:
+		game.Load(p)
+	case Travel:
+		game.startTravel(p, input.Pos)
+	case TravelStairs:
+		game.travelToStairs(p)
+	case KeepTraveling:
+		game.travelStep(p)
+	case Explore:
+		game.startExplore(p)
 	}
 }
 
@@ -701,6 +935,13 @@ func getNeighbors(level *Level, pos Pos) []Pos {
 }
 
 func (level *Level) astar(start, goal Pos) []Pos {
+	return astarWith(start, goal, func(pos Pos) []Pos {
+		return getNeighbors(level, pos)
+	})
+}
+
+// astarWith lets the caller decide which tiles can be walked, eg. players only path through what they know
+func astarWith(start, goal Pos, neighbors func(Pos) []Pos) []Pos {
 	frontier := make(pqueue, 0, 8) // Start at 8 instead of growing/shrinking frontier
 	frontier = frontier.push(start, 1)
 
@@ -740,7 +981,7 @@ func (level *Level) astar(start, goal Pos) []Pos {
 			return path
 		}
 
-		for _, next := range getNeighbors(level, current) {
+		for _, next := range neighbors(current) {
 			newCost := costSoFar[current] + 1 // Always 1 for now
 			_, exists := costSoFar[next]
 			if !exists || newCost < costSoFar[next] {
@@ -763,9 +1004,7 @@ func (level *Level) astar(start, goal Pos) []Pos {
 func (game *Game) Run() {
 
 	// Send level state to all level channels
-	for _, lchan := range game.LevelChans {
-		lchan <- game.CurrentLevel
-	}
+	game.publishAll()
 
 	// Get an input out of our input channel
 	for input := range game.InputChan {
@@ -780,21 +1019,41 @@ func (game *Game) Run() {
 		// 	game.Level.Debug[pos] = true
 		// }
 
-		game.handleInput(input) // Pass along the input we got
-
-		// Update monsters
-		for _, monster := range game.CurrentLevel.Monsters {
-			monster.Update(game.CurrentLevel)
+		if input.Typ == KeepTraveling {
+			// A UI can ask once more after the route already ended, don't spend a turn on it
+			if p := game.playerFor(input.LevelChannel); p == nil || !p.Traveling() {
+				game.publishAll() // Whoever sent it is still waiting to hear back, eg. netplay
+				continue
+			}
 		}
 
+		game.reloadMaps()
+		game.handleInput(input) // Pass along the input we got
+		game.Turn++
+		game.endRuns()
+
 		if len(game.LevelChans) == 0 {
 			// All the windows have been closed
 			return
 		}
 
-		// Send game state updates
-		for _, lchan := range game.LevelChans {
-			lchan <- game.CurrentLevel
+		// Update monsters on every level someone is on, once each
+		updated := make(map[*Level]bool)
+		for _, player := range game.Players {
+			level := player.level
+			if updated[level] {
+				continue
+			}
+			updated[level] = true
+			for _, monster := range level.Monsters {
+				monster.Update(level)
+			}
+		}
+		game.endRuns()
+		if len(game.LevelChans) == 0 {
+			return // The monsters got everyone
 		}
+
+		game.publishAll()
 	}
 }
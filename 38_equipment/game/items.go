@@ -14,6 +14,8 @@ const (
 type Item struct {
 	Typ ItemType
 	Entity
+	Value int    // Price in gold
+	Light *Light // Glowing items light up where they are, or whoever carries them
 	power float64
 }
 
@@ -26,6 +28,8 @@ func NewSword(p Pos) *Item {
 			Name: "Sword",
 			Rune: 's',
 		},
+		Value: 50,
+		Light: &Light{80, 140, 255, 2, false}, // It glows faintly blue
 		power: 2.0,
 	}
 }
@@ -39,6 +43,18 @@ func NewHelmet(p Pos) *Item {
 			Name: "Helmet",
 			Rune: 'h',
 		},
+		Value: 30,
 		power: 0.50,
 	}
 }
+
+// NewItem makes an item from its name, for items that come from data files
+func NewItem(name string, p Pos) *Item {
+	switch name {
+	case "Sword":
+		return NewSword(p)
+	case "Helmet":
+		return NewHelmet(p)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package game
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+const (
+	genWidth    = 60
+	genHeight   = 30
+	genMaxRooms = 9
+)
+
+// room is an axis-aligned rectangle of floor tiles
+type room struct {
+	x, y, w, h int
+}
+
+func (r room) center() Pos {
+	return Pos{r.x + r.w/2, r.y + r.h/2}
+}
+
+func (r room) overlaps(other room) bool {
+	// Leave at least one tile of wall between rooms
+	return r.x <= other.x+other.w && r.x+r.w >= other.x && r.y <= other.y+other.h && r.y+r.h >= other.y
+}
+
+// randomPos picks any floor tile inside the room
+func (r room) randomPos() Pos {
+	return Pos{r.x + rand.Intn(r.w), r.y + rand.Intn(r.h)}
+}
+
+// newLevel makes an empty level with all of its maps allocated
+func newLevel(name string) *Level {
+	level := &Level{}
+	level.Name = name
+	level.Depth = 1
+	level.Debug = make(map[Pos]bool)
+	level.Events = make([]string, 10)
+	level.Monsters = make(map[Pos]*Monster)
+	level.NPCs = make(map[Pos]*NPC)
+	level.Items = make(map[Pos][]*Item)
+	level.Portals = make(map[Pos]*LevelPos)
+	level.Traps = make(map[Pos]*Trap)
+	level.Lights = make(map[Pos]*Light)
+	return level
+}
+
+// generateLevel carves out rooms and corridors, and returns the new level
+// along with the position of the stair the player arrives on
+func generateLevel(name string, depth int, arriveOn rune) (*Level, Pos) {
+	level := newLevel(name)
+	level.Depth = depth
+	level.Map = make([][]Tile, genHeight)
+	for i := range level.Map {
+		level.Map[i] = make([]Tile, genWidth) // Everything starts as Blank
+	}
+
+	// Keep trying random rooms, throwing away the ones that overlap
+	rooms := make([]room, 0, genMaxRooms)
+	for attempts := 0; (attempts < 200 || len(rooms) < 2) && len(rooms) < genMaxRooms; attempts++ {
+		r := room{w: 4 + rand.Intn(8), h: 3 + rand.Intn(5)}
+		r.x = 1 + rand.Intn(genWidth-r.w-2)
+		r.y = 1 + rand.Intn(genHeight-r.h-2)
+		ok := true
+		for _, other := range rooms {
+			if r.overlaps(other) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			rooms = append(rooms, r)
+		}
+	}
+
+	for i, r := range rooms {
+		for y := r.y; y < r.y+r.h; y++ {
+			for x := r.x; x < r.x+r.w; x++ {
+				level.Map[y][x].Rune = DirtFloor
+			}
+		}
+		// Join every room to the one before it with an L-shaped corridor
+		if i > 0 {
+			level.carveCorridor(rooms[i-1].center(), r.center())
+		}
+	}
+
+	// Wrap every floor tile in stone
+	for y, row := range level.Map {
+		for x, tile := range row {
+			if tile.Rune != Blank {
+				continue
+			}
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					pos := Pos{x + dx, y + dy}
+					if inRange(level, pos) && level.Map[pos.Y][pos.X].Rune == DirtFloor {
+						level.Map[y][x].Rune = StoneWall
+					}
+				}
+			}
+		}
+	}
+
+	// The stair we arrive on goes in the first room, the other one in the last
+	leaveBy := DownStair
+	if arriveOn == DownStair {
+		leaveBy = UpStair
+	}
+	arrival := rooms[0].center()
+	level.Map[arrival.Y][arrival.X].OverlayRune = arriveOn
+	exit := rooms[len(rooms)-1].center()
+	level.Map[exit.Y][exit.X].OverlayRune = leaveBy
+
+	// Deeper levels have more monsters and more traps
+	for i := 0; i < 2+depth; i++ {
+		r := rooms[1+rand.Intn(len(rooms)-1)]
+		pos := r.randomPos()
+		if level.Map[pos.Y][pos.X].OverlayRune != Blank || level.Monsters[pos] != nil {
+			continue
+		}
+		level.Monsters[pos] = newRandomMonster(pos, depth)
+	}
+	level.monsterCap = len(level.Monsters)
+	level.placeTorches(rooms)
+	for i := 0; i < depth/2; i++ {
+		r := rooms[1+rand.Intn(len(rooms)-1)]
+		pos := r.randomPos()
+		if level.Map[pos.Y][pos.X].OverlayRune != Blank {
+			continue
+		}
+		switch rand.Intn(3) {
+		case 0:
+			level.Traps[pos] = NewSpikeTrap(pos)
+		case 1:
+			level.Traps[pos] = NewTeleportTrap(pos)
+		case 2:
+			level.Traps[pos] = NewAlarmTrap(pos)
+		}
+	}
+
+	return level, arrival
+}
+
+func (level *Level) carveCorridor(from, to Pos) {
+	x, y := from.X, from.Y
+	for x != to.X {
+		level.Map[y][x].Rune = DirtFloor
+		if x < to.X {
+			x++
+		} else {
+			x--
+		}
+	}
+	for y != to.Y {
+		level.Map[y][x].Rune = DirtFloor
+		if y < to.Y {
+			y++
+		} else {
+			y--
+		}
+	}
+}
+
+// connectStairs links an unconnected stair to a freshly generated level, in both directions
+func (game *Game) connectStairs(level *Level, pos Pos) *LevelPos {
+	stair := level.Map[pos.Y][pos.X].OverlayRune
+	depth := level.Depth + 1
+	arriveOn := UpStair
+	if stair == UpStair {
+		if level.Depth <= 1 {
+			return nil // Nothing above the first level
+		}
+		depth = level.Depth - 1
+		arriveOn = DownStair
+	}
+
+	name := "depth" + strconv.Itoa(depth) + "-" + strconv.Itoa(len(game.Levels))
+	nextLevel, arrival := generateLevel(name, depth, arriveOn)
+	nextLevel.LastTurn = game.Turn // Nothing to catch up on yet
+	game.Levels[name] = nextLevel
+
+	level.Portals[pos] = &LevelPos{nextLevel, arrival}
+	nextLevel.Portals[arrival] = &LevelPos{level, pos}
+	return level.Portals[pos]
+}
+
+// assignDepths walks the portals from the starting level so hand-authored levels know how deep they are
+func (game *Game) assignDepths(start *Level) {
+	start.Depth = 1
+	frontier := []*Level{start}
+	visited := make(map[*Level]bool)
+	visited[start] = true
+	for len(frontier) > 0 {
+		current := frontier[0]
+		frontier = frontier[1:]
+		for pos, levelAndPos := range current.Portals {
+			next := levelAndPos.Level
+			if visited[next] {
+				continue
+			}
+			switch current.Map[pos.Y][pos.X].OverlayRune {
+			case DownStair:
+				next.Depth = current.Depth + 1
+			case UpStair:
+				next.Depth = current.Depth - 1
+			default:
+				next.Depth = current.Depth
+			}
+			visited[next] = true
+			frontier = append(frontier, next)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package game
+
+import "math/rand"
+
+// Light is something that lights up the tiles around it. UIs work out how far it
+// reaches, since it's only for show and the game doesn't need to know.
+type Light struct {
+	R, G, B uint8
+	Radius  int
+	Flicker bool // Flames waver
+}
+
+// Torch represented by a character in map files
+const Torch = '*'
+
+// NewTorch is a flame on the floor, placed in maps with '*'
+func NewTorch() *Light {
+	return &Light{255, 160, 60, 6, true}
+}
+
+// newLamp is the light every player carries
+func newLamp() *Light {
+	return &Light{255, 240, 200, 4, false}
+}
+
+// Chance a generated room gets a torch
+const torchChance = 3 // One in
+
+// placeTorches lights some of a generated level's rooms
+func (level *Level) placeTorches(rooms []room) {
+	for _, r := range rooms {
+		if rand.Intn(torchChance) != 0 {
+			continue
+		}
+		pos := r.randomPos()
+		if level.Map[pos.Y][pos.X].OverlayRune == Blank {
+			level.Lights[pos] = NewTorch()
+		}
+	}
+}
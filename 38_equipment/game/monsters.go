@@ -1,8 +1,12 @@
 package game
 
+import "math/rand"
+
 // Monster is an enemy entity
 type Monster struct {
 	Character
+
+	origin *Monster // The game's monster a snapshot was copied from
 }
 
 // NewRat spawns a slow monster
@@ -18,6 +22,7 @@ func NewRat(p Pos) *Monster {
 				Rune: 'R',
 			},
 			Hitpoints:    200,
+			MaxHitpoints: 200,
 			Strength:     5,
 			Speed:        1.5,
 			ActionPoints: 0.0,
@@ -37,6 +42,7 @@ func NewSpider(p Pos) *Monster {
 				Rune: 'S',
 			},
 			Hitpoints:    200,
+			MaxHitpoints: 200,
 			Strength:     0,
 			Speed:        1.0,
 			ActionPoints: 0.0,
@@ -45,12 +51,17 @@ func NewSpider(p Pos) *Monster {
 	}
 }
 
-// Update searches for player position
+// Update chases the nearest player who can see the monster
 func (m *Monster) Update(level *Level) {
 	m.ActionPoints += m.Speed
-	playerPos := level.Player.Pos
+	target := level.nearestVisiblePlayer(m.Pos)
+	if target == nil {
+		// Nobody to chase, pass turn
+		m.Pass()
+		return
+	}
 	apInt := int(m.ActionPoints)
-	positions := level.astar(m.Pos, playerPos)
+	positions := level.astar(m.Pos, target.Pos)
 	if len(positions) == 0 {
 		// Nothing we can do, pass turn
 		m.Pass()
@@ -66,6 +77,17 @@ func (m *Monster) Update(level *Level) {
 	}
 }
 
+// NewMonster makes a monster from its name, for monsters that come from data files
+func NewMonster(name string, p Pos) *Monster {
+	switch name {
+	case "Rat":
+		return NewRat(p)
+	case "Spider":
+		return NewSpider(p)
+	}
+	return nil
+}
+
 // Pass prevents monsters from building up large sums of action points
 func (m *Monster) Pass() {
 	m.ActionPoints -= m.Speed
@@ -89,21 +111,42 @@ func (m *Monster) Kill(level *Level) {
 // Move moves towards the player position
 func (m *Monster) Move(to Pos, level *Level) {
 	_, exists := level.Monsters[to] // Is there something at the position we want to move to?
-	if !exists && to != level.Player.Pos {
+	player := level.playerAt(to)
+	if !exists && player == nil {
 		delete(level.Monsters, m.Pos) // Delete current, add new
 		level.Monsters[to] = m
 		m.Pos = to
 		return
 	}
 	// If there is another monster in the way, don't attack the player
-	if to == level.Player.Pos {
-		level.Attack(&m.Character, &level.Player.Character)
+	if player != nil {
+		player.Stats.DamageTaken += level.Attack(&m.Character, &player.Character)
 		if m.Hitpoints <= 0 {
 			// Kill monster and drop any items
 			m.Kill(level)
 		}
-		if level.Player.Hitpoints <= 0 {
-			panic("ded")
+		if player.Hitpoints <= 0 {
+			player.die("a " + m.Name)
 		}
 	}
 }
+
+// scale makes monsters tougher the deeper they are found
+func (m *Monster) scale(depth int) {
+	bonus := 1.0 + 0.25*float64(depth-1)
+	m.Hitpoints = int(float64(m.Hitpoints) * bonus)
+	m.MaxHitpoints = m.Hitpoints
+	m.Strength = int(float64(m.Strength) * bonus)
+}
+
+// newRandomMonster picks one of the monster types, made tougher for the depth
+func newRandomMonster(p Pos, depth int) *Monster {
+	var monster *Monster
+	if rand.Intn(2) == 0 {
+		monster = NewRat(p)
+	} else {
+		monster = NewSpider(p)
+	}
+	monster.scale(depth)
+	return monster
+}
@@ -0,0 +1,138 @@
+package game
+
+import "strconv"
+
+// newPlayer makes a fresh hero, numbered so several players can tell each other apart
+func newPlayer(number int) *Player {
+	player := &Player{} // Player used to not be a pointer
+	player.Strength = 5
+	player.Hitpoints = 100
+	player.MaxHitpoints = 100
+	player.Name = "GoMan"
+	if number > 1 {
+		player.Name += " " + strconv.Itoa(number)
+	}
+	player.Rune = '@'
+	player.Speed = 1.0
+	player.ActionPoints = 0
+	player.SightRange = 7
+	player.Perception = 8
+	player.Flags = make(map[string]bool)
+	player.Gold = 50
+	player.Light = newLamp()
+	player.Stats = newStats()
+	player.visible = make(map[Pos]bool)
+	player.seen = make(map[string]map[Pos]bool)
+	return player
+}
+
+// spawnPlayers puts one player for each window on the starting level, bunched up around the '@'
+func (game *Game) spawnPlayers(start *Level, count int) {
+	game.Players = make([]*Player, 0, count)
+	for i := 1; i <= count; i++ {
+		player := newPlayer(i)
+		player.Pos = start.spawn
+		if start.playerAt(player.Pos) != nil {
+			pos, ok := start.freeTileNear(player.Pos)
+			if !ok {
+				panic("No room for player " + strconv.Itoa(i))
+			}
+			player.Pos = pos
+		}
+		start.addPlayer(player)
+		player.Stats.visit(start)
+		start.resetVisibility(player)
+		game.Players = append(game.Players, player)
+	}
+}
+
+// CurrentLevel is the level the player is on
+func (player *Player) CurrentLevel() *Level {
+	return player.level
+}
+
+// playerFor finds the player controlled by a level channel, or nil if that window has closed.
+// Inputs that don't say where they came from belong to the first player.
+func (game *Game) playerFor(lchan chan *Level) *Player {
+	if lchan == nil && len(game.Players) > 0 {
+		return game.Players[0]
+	}
+	for i, c := range game.LevelChans {
+		if c == lchan {
+			return game.Players[i]
+		}
+	}
+	return nil
+}
+
+// removePlayerFor drops a closed window, and the player it controlled, from the game
+func (game *Game) removePlayerFor(lchan chan *Level) {
+	for i, c := range game.LevelChans {
+		if c == lchan {
+			game.Players[i].level.removePlayer(game.Players[i])
+			game.LevelChans = append(game.LevelChans[:i], game.LevelChans[i+1:]...)
+			game.Players = append(game.Players[:i], game.Players[i+1:]...)
+			return
+		}
+	}
+}
+
+func (level *Level) addPlayer(player *Player) {
+	player.level = level
+	level.Players = append(level.Players, player)
+}
+
+func (level *Level) removePlayer(player *Player) {
+	for i, p := range level.Players {
+		if p == player {
+			level.Players = append(level.Players[:i], level.Players[i+1:]...)
+			return
+		}
+	}
+}
+
+// playerAt returns the player standing on pos, or nil
+func (level *Level) playerAt(pos Pos) *Player {
+	for _, player := range level.Players {
+		if player.Pos == pos {
+			return player
+		}
+	}
+	return nil
+}
+
+// nearestVisiblePlayer picks the closest player who can see pos, or nil if nobody can
+func (level *Level) nearestVisiblePlayer(pos Pos) *Player {
+	var nearest *Player
+	nearestDist := 0
+	for _, player := range level.Players {
+		if !player.visible[pos] || player.ended != "" {
+			continue // Leave the dead alone
+		}
+		xDist := pos.X - player.X
+		yDist := pos.Y - player.Y
+		dist := xDist*xDist + yDist*yDist
+		if nearest == nil || dist < nearestDist {
+			nearest = player
+			nearestDist = dist
+		}
+	}
+	return nearest
+}
+
+// updateVisibility redraws line of sight for everyone on the level, eg. after a door opens
+func (level *Level) updateVisibility() {
+	for _, player := range level.Players {
+		level.resetVisibility(player)
+	}
+}
+
+// seenOn gets the tiles the player remembers on a level
+func (player *Player) seenOn(level *Level) map[Pos]bool {
+	seen := player.seen[level.Name]
+	if seen == nil {
+		seen = make(map[Pos]bool)
+		player.seen[level.Name] = seen
+	}
+	return seen
+}
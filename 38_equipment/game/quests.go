@@ -0,0 +1,284 @@
+package game
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// ObjectiveType is a tagged union/discriminating union/sum type
+type ObjectiveType int
+
+const (
+	// KillObjective counts monsters killed with a given name
+	KillObjective ObjectiveType = iota
+	// RetrieveObjective counts items picked up on a given level
+	RetrieveObjective
+	// ReachObjective is done once the player stands on a given position
+	ReachObjective
+)
+
+// Objective is one thing the player has to do to finish a quest
+type Objective struct {
+	Typ      ObjectiveType
+	Target   string // Monster or item name
+	Level    string // Level name, empty matches any level
+	Pos      Pos
+	Count    int
+	Progress int
+}
+
+// Done checks if the objective has been met
+func (o *Objective) Done() bool {
+	return o.Progress >= o.Count
+}
+
+// String describes the objective for the quest journal
+func (o *Objective) String() string {
+	switch o.Typ {
+	case KillObjective:
+		return "Kill " + o.Target + " " + strconv.Itoa(o.Progress) + "/" + strconv.Itoa(o.Count)
+	case RetrieveObjective:
+		return "Retrieve " + o.Target + " from " + o.Level + " " + strconv.Itoa(o.Progress) + "/" + strconv.Itoa(o.Count)
+	case ReachObjective:
+		if o.Done() {
+			return "Reached " + o.Level
+		}
+		return "Reach " + o.Level + " at " + strconv.Itoa(o.Pos.X) + "," + strconv.Itoa(o.Pos.Y)
+	}
+	return ""
+}
+
+// Quest is a list of objectives with a reward at the end
+type Quest struct {
+	ID          string
+	Name        string
+	Description string
+	Objectives  []*Objective
+	RewardGold  int
+	RewardItems []string
+	Done        bool
+}
+
+// questTrigger starts a quest when the player steps on a position
+type questTrigger struct {
+	quest string
+	level string
+	pos   Pos
+}
+
+// loadQuests reads quest definitions from file
+//
+// Each quest starts with @id and is followed by one keyword per line:
+//
+//	name Rat Catcher
+//	text Kill the rats on level1.
+//	kill Rat 3
+//	retrieve Helmet level2 1
+//	reach level1 30 20
+//	reward gold 100
+//	reward item Sword
+//	trigger level1 20 18
+func loadQuests() (map[string]*Quest, []questTrigger) {
+	quests := make(map[string]*Quest)
+	triggers := make([]questTrigger, 0)
+	data := files.MustRead("game/quests.txt")
+
+	var current *Quest
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '@' {
+			current = &Quest{ID: strings.TrimSpace(line[1:])}
+			quests[current.ID] = current
+			continue
+		}
+		if current == nil {
+			panic("Quest line before first quest: " + line)
+		}
+		words := strings.Fields(line)
+		rest := strings.TrimSpace(line[len(words[0]):])
+		switch words[0] {
+		case "name":
+			current.Name = rest
+		case "text":
+			current.Description = rest
+		case "kill":
+			current.Objectives = append(current.Objectives, &Objective{Typ: KillObjective, Target: words[1], Count: atoi(words[2])})
+		case "retrieve":
+			current.Objectives = append(current.Objectives, &Objective{Typ: RetrieveObjective, Target: words[1], Level: words[2], Count: atoi(words[3])})
+		case "reach":
+			current.Objectives = append(current.Objectives, &Objective{Typ: ReachObjective, Level: words[1], Pos: Pos{atoi(words[2]), atoi(words[3])}, Count: 1})
+		case "reward":
+			if words[1] == "gold" {
+				current.RewardGold += atoi(words[2])
+			} else {
+				current.RewardItems = append(current.RewardItems, words[2])
+			}
+		case "trigger":
+			triggers = append(triggers, questTrigger{current.ID, words[1], Pos{atoi(words[2]), atoi(words[3])}})
+		default:
+			panic("Invalid quest line: " + line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		panic(err)
+	}
+	return quests, triggers
+}
+
+// checkQuests makes sure every reach objective and trigger is somewhere a player can stand.
+// Stairs and portals take the player away before it's checked, and winning needs every quest.
+// Quests handed out by triggers and dialogue have to exist, or StartQuest panics mid game.
+func (game *Game) checkQuests() {
+	for name, start := range game.Dialogues {
+		for _, id := range dialogueQuests(start) {
+			if game.QuestDefs[id] == nil {
+				panic("Dialogue " + name + " starts quest " + id + ", but there is no such quest")
+			}
+		}
+	}
+	for _, trigger := range game.questTriggers {
+		if game.QuestDefs[trigger.quest] == nil {
+			panic("Trigger on " + trigger.level + " starts quest " + trigger.quest + ", but there is no such quest")
+		}
+	}
+	for _, quest := range game.QuestDefs {
+		for _, objective := range quest.Objectives {
+			if objective.Typ == ReachObjective {
+				game.checkQuestPos(quest.ID, objective.Level, objective.Pos)
+			}
+		}
+	}
+	for _, trigger := range game.questTriggers {
+		game.checkQuestPos(trigger.quest, trigger.level, trigger.pos)
+	}
+}
+
+func (game *Game) checkQuestPos(id, levelName string, pos Pos) {
+	where := "Quest " + id + " needs " + levelName + " " + strconv.Itoa(pos.X) + "," + strconv.Itoa(pos.Y) + ", "
+	level := game.Levels[levelName]
+	if level == nil {
+		panic(where + "but there is no such level")
+	}
+	if !inRange(level, pos) {
+		panic(where + "but it is off the map")
+	}
+	t := level.Map[pos.Y][pos.X]
+	switch {
+	case t.Rune == StoneWall || t.Rune == Blank || t.OverlayRune == SecretDoor:
+		panic(where + "but it is a wall")
+	case isStair(level, pos) || level.Portals[pos] != nil:
+		panic(where + "but it is a stair or portal, which moves the player before it counts")
+	}
+}
+
+func atoi(s string) int {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+// StartQuest gives the player a fresh copy of a quest, unless they already have it
+func (game *Game) StartQuest(player *Player, id string) {
+	level := player.level
+	def := game.QuestDefs[id]
+	if def == nil {
+		panic("Unknown quest " + id)
+	}
+	for _, quest := range player.Quests {
+		if quest.ID == id {
+			return
+		}
+	}
+	quest := *def
+	quest.Objectives = make([]*Objective, len(def.Objectives))
+	for i, objective := range def.Objectives {
+		o := *objective
+		quest.Objectives[i] = &o
+	}
+	player.Quests = append(player.Quests, &quest)
+	level.AddEvent("New quest: " + quest.Name)
+	game.updateQuests(player) // Objectives like reach might already be met
+}
+
+// questKill counts a kill towards kill objectives
+func (game *Game) questKill(player *Player, monster *Monster) {
+	for _, quest := range player.Quests {
+		for _, objective := range quest.Objectives {
+			if !quest.Done && objective.Typ == KillObjective && objective.Target == monster.Name && !objective.Done() {
+				objective.Progress++
+			}
+		}
+	}
+	game.updateQuests(player)
+}
+
+// questPickUp counts a picked up item towards retrieve objectives
+func (game *Game) questPickUp(player *Player, item *Item) {
+	level := player.level
+	for _, quest := range player.Quests {
+		for _, objective := range quest.Objectives {
+			if !quest.Done && objective.Typ == RetrieveObjective && objective.Target == item.Name && objective.Level == level.Name && !objective.Done() {
+				objective.Progress++
+			}
+		}
+	}
+	game.updateQuests(player)
+}
+
+// questMove checks reach objectives and quest triggers after the player moves
+func (game *Game) questMove(player *Player) {
+	level := player.level
+	for _, trigger := range game.questTriggers {
+		if trigger.level == level.Name && trigger.pos == player.Pos {
+			game.StartQuest(player, trigger.quest)
+		}
+	}
+	for _, quest := range player.Quests {
+		for _, objective := range quest.Objectives {
+			if !quest.Done && objective.Typ == ReachObjective && objective.Level == level.Name && objective.Pos == player.Pos {
+				objective.Progress = objective.Count
+			}
+		}
+	}
+	game.updateQuests(player)
+}
+
+// updateQuests hands out rewards for quests whose objectives are all done
+func (game *Game) updateQuests(player *Player) {
+	level := player.level
+	for _, quest := range player.Quests {
+		if quest.Done {
+			continue
+		}
+		done := true
+		for _, objective := range quest.Objectives {
+			if !objective.Done() {
+				done = false
+			}
+		}
+		if !done {
+			continue
+		}
+		quest.Done = true
+		level.AddEvent("Quest complete: " + quest.Name)
+		if quest.RewardGold > 0 {
+			player.Gold += quest.RewardGold
+			level.AddEvent(player.Name + " received " + strconv.Itoa(quest.RewardGold) + " gold")
+		}
+		for _, name := range quest.RewardItems {
+			item := NewItem(name, player.Pos)
+			if item != nil {
+				player.Items = append(player.Items, item)
+				level.AddEvent(player.Name + " received 1x " + item.Name)
+			}
+		}
+	}
+}
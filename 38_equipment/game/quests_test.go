@@ -0,0 +1,39 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// wantPanic runs f, and fails unless it panics with a message containing want
+func wantPanic(t *testing.T, want string, f func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		r := recover()
+		if r == nil {
+			t.Fatalf("Didn't panic, want %q", want)
+		}
+		if msg := fmt.Sprint(r); !strings.Contains(msg, want) {
+			t.Fatalf("Panicked with %q, want %q", msg, want)
+		}
+	}()
+	f()
+}
+
+func TestCheckQuestsUnknownIDs(t *testing.T) {
+	inTempDir(t)
+	game := NewGame(1) // Checks the real quests and dialogue
+
+	// Hide the quest one choice in, so only following the graph finds it
+	end := &DialogueNode{Choices: []*DialogueChoice{{Description: "Sure", effects: []dialogueRule{{"quest", "nope"}}}}}
+	start := &DialogueNode{Choices: []*DialogueChoice{{Description: "Hello", Next: end}}}
+	end.Choices = append(end.Choices, &DialogueChoice{Description: "Again", Next: start})
+	game.Dialogues["stranger"] = start
+	wantPanic(t, "Dialogue stranger starts quest nope", game.checkQuests)
+
+	delete(game.Dialogues, "stranger")
+	game.questTriggers = append(game.questTriggers, questTrigger{"nope", "level1", Pos{}})
+	wantPanic(t, "Trigger on level1 starts quest nope", game.checkQuests)
+}
@@ -0,0 +1,71 @@
+package game
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// With -assets, maps are watched so editing one changes the level for everyone on it
+// after the next turn. Only the tiles and torches are swapped: monsters, items and
+// traps are wherever the game has got to, so they stay put. Tiles the edit didn't
+// touch keep what happened to them, eg. doors stay open and found secret doors stay found.
+// Maps can't change size, everything placed on them would be in the wrong spot.
+
+// reloadMaps swaps in the tiles of maps that changed since the last turn
+func (game *Game) reloadMaps() {
+	for {
+		select {
+		case filename := <-game.mapChanges:
+			game.reloadMap(filename)
+		default:
+			return
+		}
+	}
+}
+
+func (game *Game) reloadMap(filename string) {
+	name := strings.TrimSuffix(path.Base(filename), ".map")
+	level := game.Levels[name]
+	if level == nil {
+		fmt.Println("New map", filename, "is loaded when the game next starts")
+		return
+	}
+	defer func() {
+		// Editors save half written files, keep playing the old map until it's fixed
+		if r := recover(); r != nil {
+			fmt.Println("Couldn't reload", filename+":", r)
+		}
+	}()
+	fresh := loadLevel(name, files.MustRead(filename))
+	if !sameSize(level.loaded, fresh.Map) {
+		fmt.Println("Couldn't reload", filename+": the map changed size, restart to play it")
+		return
+	}
+	for y, row := range fresh.Map {
+		for x, tile := range row {
+			was := level.loaded[y][x]
+			if tile.Rune == was.Rune && tile.OverlayRune == was.OverlayRune {
+				fresh.Map[y][x] = level.Map[y][x] // Not edited, keep it how the game left it
+			}
+		}
+	}
+	level.Map = fresh.Map
+	level.loaded = fresh.loaded
+	level.Lights = fresh.Lights
+	level.updateVisibility()
+	fmt.Println("Reloaded", filename)
+}
+
+// sameSize is true if two maps have the same rows, each as long as the other's
+func sameSize(a, b [][]Tile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for y := range a {
+		if len(a[y]) != len(b[y]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package game
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxproske/games-with-go/38_equipment/assets"
+)
+
+// editMap writes a changed copy of a built in map where -assets would read it
+func editMap(t *testing.T, name string, lines [][]byte) string {
+	t.Helper()
+	filename := "game/maps/" + name + ".map"
+	if err := os.MkdirAll(filepath.Dir(assets.Path(filename)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(assets.Path(filename), bytes.Join(lines, []byte("\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestReloadMap(t *testing.T) {
+	inTempDir(t)
+	game := NewGame(1)
+	assets.Dir = "." // Only after NewGame, which would start watching it
+	t.Cleanup(func() { assets.Dir = "" })
+	level := game.Levels["level1"]
+	lines := bytes.Split(files.MustRead("game/maps/level1.map"), []byte("\n"))
+
+	player := game.Players[0]
+	if player.CurrentLevel() != level {
+		t.Fatal("Players need to start on level1 for this test")
+	}
+
+	// Open a door, then make a floor tile somewhere else into a wall, and put a torch on another
+	var door Pos
+	foundDoor := false
+	floors := make([]Pos, 0)
+	for y, line := range lines {
+		for x, c := range line {
+			if c == '|' && !foundDoor {
+				door, foundDoor = Pos{x, y}, true
+			}
+			if c == '.' {
+				floors = append(floors, Pos{x, y})
+			}
+		}
+	}
+	if !foundDoor || len(floors) < 2 {
+		t.Fatal("level1 needs a door and some floor for this test")
+	}
+	floor, torch := floors[0], floors[1]
+	level.Map[door.Y][door.X].OverlayRune = OpenDoor
+	lines[floor.Y][floor.X] = '#'
+	lines[torch.Y][torch.X] = Torch
+	filename := editMap(t, "level1", lines)
+
+	before := player.snapshot()
+	game.reloadMap(filename)
+	if r := level.Map[floor.Y][floor.X].Rune; r != StoneWall {
+		t.Fatalf("Edited tile is %q, want a wall", r)
+	}
+	if r := level.Map[door.Y][door.X].OverlayRune; r != OpenDoor {
+		t.Fatalf("Door the player opened is %q after a reload, want it still open", r)
+	}
+
+	// UIs reading updates get the new torch too
+	after := Diff(before, player.snapshot()).Apply(before)
+	if after.Lights[torch] == nil {
+		t.Fatal("Update after the reload is missing the new torch")
+	}
+	if len(after.Lights) != len(level.Lights) {
+		t.Fatalf("Update has %d lights, the level has %d", len(after.Lights), len(level.Lights))
+	}
+
+	// A map that changed size is left alone
+	was := level.Map
+	editMap(t, "level1", append(lines, []byte("#"), []byte("#")))
+	game.reloadMap(filename)
+	if len(level.Map) != len(was) || &level.Map[0][0] != &was[0][0] {
+		t.Fatal("Reloaded a map that changed size")
+	}
+}
@@ -0,0 +1,349 @@
+package game
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+)
+
+const (
+	saveFileName = "save.json"
+	saveVersion  = 2
+)
+
+// Levels point at each other through portals, so everything is flattened
+// into plain structs with names instead of pointers before being written out
+
+type saveFile struct {
+	Version int
+	Turn    int
+	Players []savedPlayer // In the same order as Game.Players
+	Levels  []savedLevel
+}
+
+type savedItem struct {
+	Name string
+	Pos  Pos
+}
+
+type savedCharacter struct {
+	Entity
+	Hitpoints    int
+	MaxHitpoints int
+	Strength     int
+	Speed        float64
+	ActionPoints float64
+	SightRange   int
+	Perception   int
+	Items        []savedItem
+	Helmet       string
+	Weapon       string
+}
+
+type savedPlayer struct {
+	savedCharacter
+	Flags  map[string]bool
+	Gold   int
+	Quests []*Quest
+	Level  string
+	Seen   map[string][]Pos // Level name to tiles the player has seen
+
+	Stats        Stats
+	Achievements []string
+}
+
+type savedNPC struct {
+	savedCharacter
+	Dialogue    string
+	Merchant    bool
+	Stock       []string
+	Wares       []string
+	LastRestock int
+}
+
+type savedPortal struct {
+	Pos   Pos
+	Level string
+	To    Pos
+}
+
+type savedLight struct {
+	Pos Pos
+	Light
+}
+
+type savedTrap struct {
+	Typ    TrapType
+	Pos    Pos
+	Hidden bool
+}
+
+type savedLevel struct {
+	Name       string
+	Depth      int
+	LastTurn   int
+	MonsterCap int
+	Map        [][]Tile
+	Monsters   []savedCharacter
+	NPCs       []savedNPC
+	Items      []savedItem
+	Portals    []savedPortal
+	Traps      []savedTrap
+	Lights     []savedLight
+	Events     []string
+	EventPos   int
+}
+
+func saveItem(item *Item) savedItem {
+	return savedItem{item.Name, item.Pos}
+}
+
+func loadItem(saved savedItem) *Item {
+	item := NewItem(saved.Name, saved.Pos)
+	if item == nil {
+		panic("Unknown item in save file: " + saved.Name)
+	}
+	return item
+}
+
+func saveCharacter(c *Character) savedCharacter {
+	saved := savedCharacter{
+		Entity:       c.Entity,
+		Hitpoints:    c.Hitpoints,
+		MaxHitpoints: c.MaxHitpoints,
+		Strength:     c.Strength,
+		Speed:        c.Speed,
+		ActionPoints: c.ActionPoints,
+		SightRange:   c.SightRange,
+		Perception:   c.Perception,
+	}
+	for _, item := range c.Items {
+		saved.Items = append(saved.Items, saveItem(item))
+	}
+	if c.Helmet != nil {
+		saved.Helmet = c.Helmet.Name
+	}
+	if c.Weapon != nil {
+		saved.Weapon = c.Weapon.Name
+	}
+	return saved
+}
+
+func loadCharacter(saved savedCharacter) Character {
+	c := Character{
+		Entity:       saved.Entity,
+		Hitpoints:    saved.Hitpoints,
+		MaxHitpoints: saved.MaxHitpoints,
+		Strength:     saved.Strength,
+		Speed:        saved.Speed,
+		ActionPoints: saved.ActionPoints,
+		SightRange:   saved.SightRange,
+		Perception:   saved.Perception,
+	}
+	for _, item := range saved.Items {
+		c.Items = append(c.Items, loadItem(item))
+	}
+	if saved.Helmet != "" {
+		c.Helmet = loadItem(savedItem{saved.Helmet, c.Pos})
+	}
+	if saved.Weapon != "" {
+		c.Weapon = loadItem(savedItem{saved.Weapon, c.Pos})
+	}
+	return c
+}
+
+// Save writes the whole game to the save file, on behalf of one of the players
+func (game *Game) Save(by *Player) {
+	save := saveFile{
+		Version: saveVersion,
+		Turn:    game.Turn,
+	}
+	for _, player := range game.Players {
+		saved := savedPlayer{
+			savedCharacter: saveCharacter(&player.Character),
+			Flags:          player.Flags,
+			Gold:           player.Gold,
+			Quests:         player.Quests,
+			Level:          player.level.Name,
+			Seen:           make(map[string][]Pos),
+			Stats:          player.Stats,
+			Achievements:   player.Achievements,
+		}
+		for name, seen := range player.seen {
+			for pos := range seen {
+				saved.Seen[name] = append(saved.Seen[name], pos)
+			}
+		}
+		save.Players = append(save.Players, saved)
+	}
+
+	for _, level := range game.Levels {
+		saved := savedLevel{
+			Name:       level.Name,
+			Depth:      level.Depth,
+			LastTurn:   level.LastTurn,
+			MonsterCap: level.monsterCap,
+			Map:        level.Map,
+			Events:     level.Events,
+			EventPos:   level.EventPos,
+		}
+		for _, monster := range level.Monsters {
+			saved.Monsters = append(saved.Monsters, saveCharacter(&monster.Character))
+		}
+		for _, npc := range level.NPCs {
+			savedNPC := savedNPC{savedCharacter: saveCharacter(&npc.Character), Dialogue: npc.Dialogue}
+			if npc.Shop != nil {
+				savedNPC.Merchant = true
+				for _, item := range npc.Shop.Stock {
+					savedNPC.Stock = append(savedNPC.Stock, item.Name)
+				}
+				savedNPC.Wares = npc.Shop.wares
+				savedNPC.LastRestock = npc.Shop.lastRestock
+			}
+			saved.NPCs = append(saved.NPCs, savedNPC)
+		}
+		for _, items := range level.Items {
+			for _, item := range items {
+				saved.Items = append(saved.Items, saveItem(item))
+			}
+		}
+		for pos, levelAndPos := range level.Portals {
+			saved.Portals = append(saved.Portals, savedPortal{pos, levelAndPos.Level.Name, levelAndPos.Pos})
+		}
+		for pos, trap := range level.Traps {
+			saved.Traps = append(saved.Traps, savedTrap{trap.Typ, pos, trap.Hidden})
+		}
+		for pos, light := range level.Lights {
+			saved.Lights = append(saved.Lights, savedLight{pos, *light})
+		}
+		save.Levels = append(save.Levels, saved)
+	}
+
+	file, err := os.Create(saveFileName)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	err = encoder.Encode(save)
+	if err != nil {
+		panic(err)
+	}
+	by.level.AddEvent("Game saved")
+}
+
+// Load replaces the game with the save file, on behalf of one of the players
+func (game *Game) Load(by *Player) {
+	file, err := os.Open(saveFileName)
+	if err != nil {
+		by.level.AddEvent("No saved game to load")
+		return
+	}
+	defer file.Close()
+	var save saveFile
+	err = json.NewDecoder(file).Decode(&save)
+	if err != nil {
+		panic(err)
+	}
+	if save.Version != saveVersion {
+		by.level.AddEvent("Save file is from a different version")
+		return
+	}
+	if len(save.Players) != len(game.Players) {
+		by.level.AddEvent("Save file is for " + strconv.Itoa(len(save.Players)) + " players")
+		return
+	}
+
+	levels := make(map[string]*Level)
+	for _, saved := range save.Levels {
+		level := newLevel(saved.Name)
+		level.Depth = saved.Depth
+		level.LastTurn = saved.LastTurn
+		level.monsterCap = saved.MonsterCap
+		level.Map = saved.Map
+		level.Events = saved.Events
+		level.EventPos = saved.EventPos
+		for _, m := range saved.Monsters {
+			monster := &Monster{Character: loadCharacter(m)}
+			level.Monsters[monster.Pos] = monster
+		}
+		for _, n := range saved.NPCs {
+			npc := &NPC{Character: loadCharacter(n.savedCharacter), Dialogue: n.Dialogue}
+			if n.Merchant {
+				npc.Shop = &Shop{wares: n.Wares, lastRestock: n.LastRestock}
+				for _, name := range n.Stock {
+					npc.Shop.Stock = append(npc.Shop.Stock, loadItem(savedItem{name, npc.Pos}))
+				}
+			}
+			level.NPCs[npc.Pos] = npc
+		}
+		for _, item := range saved.Items {
+			level.Items[item.Pos] = append(level.Items[item.Pos], loadItem(item))
+		}
+		for _, t := range saved.Traps {
+			var trap *Trap
+			switch t.Typ {
+			case SpikeTrap:
+				trap = NewSpikeTrap(t.Pos)
+			case TeleportTrap:
+				trap = NewTeleportTrap(t.Pos)
+			case AlarmTrap:
+				trap = NewAlarmTrap(t.Pos)
+			}
+			trap.Hidden = t.Hidden
+			level.Traps[t.Pos] = trap
+		}
+		for _, l := range saved.Lights {
+			light := l.Light
+			level.Lights[l.Pos] = &light
+		}
+		levels[level.Name] = level
+	}
+	// Portals can only be linked up once every level exists
+	for _, saved := range save.Levels {
+		for _, portal := range saved.Portals {
+			levels[saved.Name].Portals[portal.Pos] = &LevelPos{levels[portal.Level], portal.To}
+		}
+	}
+
+	// Players keep their windows, but everything else about them comes from the file
+	players := make([]*Player, len(save.Players))
+	for i, saved := range save.Players {
+		player := &Player{}
+		player.Character = loadCharacter(saved.savedCharacter)
+		player.Flags = saved.Flags
+		if player.Flags == nil {
+			player.Flags = make(map[string]bool)
+		}
+		player.Gold = saved.Gold
+		player.Light = newLamp()
+		player.Quests = saved.Quests
+		player.Stats = saved.Stats
+		if player.Stats.Kills == nil {
+			player.Stats.Kills = make(map[string]int) // Saved before there were stats
+		}
+		player.Achievements = saved.Achievements
+		player.visible = make(map[Pos]bool)
+		player.seen = make(map[string]map[Pos]bool)
+		for name, positions := range saved.Seen {
+			seen := make(map[Pos]bool)
+			for _, pos := range positions {
+				seen[pos] = true
+			}
+			player.seen[name] = seen
+		}
+		level := levels[saved.Level]
+		level.addPlayer(player)
+		level.resetVisibility(player)
+		players[i] = player
+	}
+
+	game.Levels = levels
+	game.Players = players
+	game.Turn = save.Turn
+	for _, level := range levels {
+		if len(level.Players) > 0 {
+			level.AddEvent("Game loaded")
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package game
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Scripts are lexed with state functions, the same way as 21_parsing/apt
+
+type tokenType int
+
+const (
+	openParen tokenType = iota
+	closeParen
+	atom   // A symbol or a number
+	quoted // A string in double quotes, without the quotes
+	lexErr // Something that can't be lexed, value says what
+)
+
+type token struct {
+	typ   tokenType
+	value string
+	line  int // For error messages
+}
+
+type lexer struct {
+	input  string
+	start  int // Start of the token we are working on
+	pos    int // Character we are pointed at
+	width  int // Width of the last character, for backing up
+	line   int
+	tokens chan token
+}
+
+type stateFunc func(*lexer) stateFunc
+
+const eof rune = -1
+
+// lexScript starts lexing on its own goroutine, so the parser can take tokens as they come
+func lexScript(input string) chan token {
+	l := &lexer{input: input, line: 1, tokens: make(chan token, 100)}
+	go l.run()
+	return l.tokens
+}
+
+func (l *lexer) run() {
+	for state := determineToken; state != nil; {
+		state = state(l)
+	}
+	close(l.tokens)
+}
+
+func determineToken(l *lexer) stateFunc {
+	for {
+		switch r := l.next(); {
+		case r == '\n':
+			l.line++
+			l.ignore()
+		case isWhiteSpace(r):
+			l.ignore()
+		case r == ';':
+			return lexComment
+		case r == '(':
+			l.emit(openParen)
+		case r == ')':
+			l.emit(closeParen)
+		case r == '"':
+			return lexQuoted
+		case r == eof:
+			return nil
+		default:
+			return lexAtom
+		}
+	}
+}
+
+// Comments run from ; to the end of the line
+func lexComment(l *lexer) stateFunc {
+	for r := l.peek(); r != '\n' && r != eof; r = l.peek() {
+		l.next()
+	}
+	l.ignore()
+	return determineToken
+}
+
+func lexAtom(l *lexer) stateFunc {
+	for r := l.peek(); !isWhiteSpace(r) && r != '(' && r != ')' && r != '"' && r != ';' && r != eof; r = l.peek() {
+		l.next()
+	}
+	l.emit(atom)
+	return determineToken
+}
+
+func lexQuoted(l *lexer) stateFunc {
+	l.ignore() // Leave the opening quote out of the string
+	for {
+		switch l.next() {
+		case '"':
+			l.backup()
+			l.emit(quoted)
+			l.next()
+			l.ignore() // And the closing one
+			return determineToken
+		case '\n', eof:
+			// Let the parser panic, so it happens on the goroutine that loaded the script
+			l.tokens <- token{lexErr, "Unterminated string on line " + strconv.Itoa(l.line) + " of a script", l.line}
+			return nil
+		}
+	}
+}
+
+func isWhiteSpace(r rune) bool {
+	return r == ' ' || r == '\n' || r == '\t' || r == '\r'
+}
+
+func (l *lexer) next() (r rune) {
+	if l.pos >= len(l.input) {
+		l.width = 0
+		return eof
+	}
+	r, l.width = utf8.DecodeRuneInString(l.input[l.pos:])
+	l.pos += l.width
+	return r
+}
+
+func (l *lexer) backup() {
+	l.pos -= l.width
+}
+
+func (l *lexer) ignore() {
+	l.start = l.pos
+}
+
+func (l *lexer) peek() rune {
+	if l.pos >= len(l.input) {
+		return eof
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[l.pos:])
+	return r
+}
+
+func (l *lexer) emit(t tokenType) {
+	l.tokens <- token{t, l.input[l.start:l.pos], l.line}
+	l.start = l.pos
+}
+
+// sexpr is either an atom or a list of sexprs
+type sexpr struct {
+	value  string // Atom, or what the string in quotes said
+	quoted bool   // It was in quotes, so it's never a number or a name
+	list   []*sexpr
+	isList bool
+	line   int
+}
+
+func (e *sexpr) String() string {
+	if !e.isList {
+		if e.quoted {
+			return strconv.Quote(e.value)
+		}
+		return e.value
+	}
+	parts := make([]string, len(e.list))
+	for i, child := range e.list {
+		parts[i] = child.String()
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// parseSexprs reads every top level expression in a script
+func parseSexprs(input string) []*sexpr {
+	tokens := lexScript(input)
+	exprs := make([]*sexpr, 0)
+	for t := range tokens {
+		exprs = append(exprs, parseSexpr(t, tokens))
+	}
+	return exprs
+}
+
+// Recursive so we can descend into lists. t is the first token of the expression.
+func parseSexpr(t token, tokens chan token) *sexpr {
+	switch t.typ {
+	case atom:
+		return &sexpr{value: t.value, line: t.line}
+	case quoted:
+		return &sexpr{value: t.value, quoted: true, line: t.line}
+	case closeParen:
+		panic("Unexpected ) on line " + strconv.Itoa(t.line) + " of a script")
+	case lexErr:
+		panic(t.value)
+	}
+	e := &sexpr{isList: true, line: t.line}
+	for {
+		next, ok := <-tokens
+		if !ok {
+			panic("Missing ) for the ( on line " + strconv.Itoa(t.line) + " of a script")
+		}
+		if next.typ == closeParen {
+			return e
+		}
+		if next.typ == lexErr {
+			panic(next.value)
+		}
+		e.list = append(e.list, parseSexpr(next, tokens))
+	}
+}
@@ -0,0 +1,412 @@
+package game
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Scripts let level designers hang behaviour off the map without writing Go.
+// They live in game/scripts.txt as S-expressions, one trigger each:
+//
+//	(on step level1 20 14           ; Player steps on a tile
+//		(when (not (flag cellarOpen))
+//			(set-flag cellarOpen)
+//			(message "A door grinds open")
+//			(set-tile 13 12 "/")
+//			(spawn Rat 13 11)
+//			(spawn Rat 13 10)))
+//	(on pickup Helmet ...)          ; Player picks up an item
+//	(on equip Sword ...)            ; Player equips an item
+//	(on kill Spider ...)            ; Player kills a monster
+//
+// x and y are where it happened. Scripts can only call the functions in
+// scriptFuncs, and have no loops, so they can't reach anything else or run forever.
+
+// script is one trigger and what to do when it fires
+type script struct {
+	on    string // step, pickup, equip or kill
+	level string // For step
+	pos   Pos    // For step
+	name  string // Item or monster name
+	body  []*sexpr
+}
+
+// scriptEnv is what a running script can see
+type scriptEnv struct {
+	game   *Game
+	player *Player
+	pos    Pos // Where the trigger fired
+	steps  int // Expressions left before we give up on the script
+}
+
+// scriptError stops a script that tried something it can't do, without stopping the game
+type scriptError string
+
+// Scripts shouldn't need anywhere near this many to do their job
+const maxScriptSteps = 1000
+
+// Scripts that set off other scripts, eg. by teleporting onto a trigger, stop this deep
+const maxScriptDepth = 8
+
+type scriptFunc struct {
+	minArgs, maxArgs int // maxArgs is -1 for any number
+	call             func(env *scriptEnv, args []interface{}) interface{}
+}
+
+var scriptFuncs map[string]scriptFunc
+
+// Filled in by init, since the functions call eval, which looks in scriptFuncs
+func init() {
+	scriptFuncs = map[string]scriptFunc{
+		"message":   {1, -1, scriptMessage},
+		"spawn":     {3, 3, scriptSpawn},
+		"teleport":  {2, 3, scriptTeleport},
+		"set-tile":  {3, 3, scriptSetTile},
+		"give":      {1, 1, scriptGive},
+		"flag":      {1, 1, scriptFlag},
+		"set-flag":  {1, 1, scriptSetFlag},
+		"has":       {1, 1, scriptHas},
+		"not":       {1, 1, scriptNot},
+		"level":     {0, 0, scriptLevel},
+		"when":      {1, -1, nil}, // Special forms only evaluate some of their arguments
+		"unless":    {1, -1, nil},
+		"=":         {2, 2, scriptEquals},
+		"+":         {2, 2, scriptPlus},
+		"-":         {2, 2, scriptMinus},
+		"player-x":  {0, 0, func(env *scriptEnv, args []interface{}) interface{} { return env.player.X }},
+		"player-y":  {0, 0, func(env *scriptEnv, args []interface{}) interface{} { return env.player.Y }},
+		"player-hp": {0, 0, func(env *scriptEnv, args []interface{}) interface{} { return env.player.Hitpoints }},
+	}
+}
+
+// loadScripts reads every trigger from file, checking as much as we can before the game starts
+func loadScripts() []*script {
+	return parseScripts(string(files.MustRead("game/scripts.txt")))
+}
+
+func parseScripts(input string) []*script {
+	scripts := make([]*script, 0)
+	for _, e := range parseSexprs(input) {
+		if !e.isList || len(e.list) < 2 || e.list[0].value != "on" {
+			panic("Scripts must start with (on ...), line " + strconv.Itoa(e.line) + ": " + e.String())
+		}
+		s := &script{on: e.list[1].value}
+		args := e.list[2:]
+		switch s.on {
+		case "step":
+			if len(args) < 3 {
+				panic("Expected (on step level x y ...) on line " + strconv.Itoa(e.line))
+			}
+			s.level = args[0].value
+			s.pos = Pos{atoi(args[1].value), atoi(args[2].value)}
+			args = args[3:]
+		case "pickup", "equip", "kill":
+			if len(args) < 1 {
+				panic("Expected (on " + s.on + " name ...) on line " + strconv.Itoa(e.line))
+			}
+			s.name = args[0].value
+			args = args[1:]
+		default:
+			panic("Unknown trigger " + s.on + " on line " + strconv.Itoa(e.line))
+		}
+		for _, statement := range args {
+			checkScript(statement)
+		}
+		s.body = args
+		scripts = append(scripts, s)
+	}
+	return scripts
+}
+
+// checkScript makes sure every call is to a function that exists, with the right number of arguments
+func checkScript(e *sexpr) {
+	if !e.isList {
+		return
+	}
+	if len(e.list) == 0 || e.list[0].isList || e.list[0].quoted {
+		panic("Expected a function call on line " + strconv.Itoa(e.line) + ": " + e.String())
+	}
+	name := e.list[0].value
+	f, exists := scriptFuncs[name]
+	if !exists {
+		panic("Unknown script function " + name + " on line " + strconv.Itoa(e.line))
+	}
+	args := len(e.list) - 1
+	if args < f.minArgs || f.maxArgs >= 0 && args > f.maxArgs {
+		panic("Wrong number of arguments to " + name + " on line " + strconv.Itoa(e.line))
+	}
+	for _, arg := range e.list[1:] {
+		checkScript(arg)
+	}
+}
+
+// runScripts fires every script for something that just happened to the player
+func (game *Game) runScripts(player *Player, on, name string, pos Pos) {
+	if game.scriptDepth >= maxScriptDepth {
+		player.level.AddEvent("Script error: scripts set each other off too many times")
+		return
+	}
+	game.scriptDepth++
+	defer func() { game.scriptDepth-- }()
+
+	for _, s := range game.scripts {
+		if s.on != on {
+			continue
+		}
+		if on == "step" && (s.level != player.level.Name || s.pos != pos) || on != "step" && s.name != name {
+			continue
+		}
+		game.runScript(s, &scriptEnv{game, player, pos, maxScriptSteps})
+	}
+}
+
+func (game *Game) runScript(s *script, env *scriptEnv) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(scriptError)
+			if !ok {
+				panic(r) // A bug in the game, not the script
+			}
+			env.player.level.AddEvent("Script error: " + string(err))
+		}
+	}()
+	for _, statement := range s.body {
+		env.eval(statement)
+	}
+}
+
+func (env *scriptEnv) eval(e *sexpr) interface{} {
+	env.steps--
+	if env.steps < 0 {
+		panic(scriptError("script took too long"))
+	}
+	if !e.isList {
+		if e.quoted {
+			return e.value
+		}
+		switch e.value {
+		case "x":
+			return env.pos.X
+		case "y":
+			return env.pos.Y
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+		if i, err := strconv.Atoi(e.value); err == nil {
+			return i
+		}
+		return e.value // Bare words are names, eg. Rat or level1
+	}
+
+	name := e.list[0].value
+	switch name {
+	case "when", "unless":
+		if truthy(env.eval(e.list[1])) != (name == "when") {
+			return nil
+		}
+		var result interface{}
+		for _, statement := range e.list[2:] {
+			result = env.eval(statement)
+		}
+		return result
+	}
+	args := make([]interface{}, len(e.list)-1)
+	for i, arg := range e.list[1:] {
+		args[i] = env.eval(arg)
+	}
+	return scriptFuncs[name].call(env, args)
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+func scriptInt(v interface{}) int {
+	i, ok := v.(int)
+	if !ok {
+		panic(scriptError("expected a number, got " + scriptString(v)))
+	}
+	return i
+}
+
+func scriptString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case string:
+		return v
+	}
+	panic("unknown script value")
+}
+
+// scriptPos checks a position is on the player's level
+func (env *scriptEnv) scriptPos(x, y interface{}) Pos {
+	pos := Pos{scriptInt(x), scriptInt(y)}
+	if !inRange(env.player.level, pos) {
+		panic(scriptError("position " + strconv.Itoa(pos.X) + "," + strconv.Itoa(pos.Y) + " is off the map"))
+	}
+	return pos
+}
+
+// (message "text" ...) adds to the event log
+func scriptMessage(env *scriptEnv, args []interface{}) interface{} {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = scriptString(arg)
+	}
+	env.player.level.AddEvent(strings.Join(parts, " "))
+	return nil
+}
+
+// (spawn Rat x y) puts a monster on the player's level, next to x,y if something is in the way
+func scriptSpawn(env *scriptEnv, args []interface{}) interface{} {
+	level := env.player.level
+	pos := env.scriptPos(args[1], args[2])
+	monster := NewMonster(scriptString(args[0]), pos)
+	if monster == nil {
+		panic(scriptError("no monster called " + scriptString(args[0])))
+	}
+	if !canWalk(level, pos) || level.playerAt(pos) != nil {
+		free, ok := level.freeTileNear(pos)
+		if !ok {
+			return false // Nowhere to put it
+		}
+		pos = free
+		monster.Pos = pos
+	}
+	monster.scale(level.Depth)
+	level.Monsters[pos] = monster
+	return true
+}
+
+// (teleport x y) or (teleport level2 x y) moves the player
+func scriptTeleport(env *scriptEnv, args []interface{}) interface{} {
+	player := env.player
+	level := player.level
+	if len(args) == 3 {
+		level = env.game.Levels[scriptString(args[0])]
+		if level == nil {
+			panic(scriptError("no level called " + scriptString(args[0])))
+		}
+		args = args[1:]
+	}
+	pos := Pos{scriptInt(args[0]), scriptInt(args[1])}
+	if !canWalk(level, pos) || level.playerAt(pos) != nil {
+		return false
+	}
+	player.Conversation = nil
+	player.Trading = nil
+	if level != player.level {
+		env.game.travel(player, player.Pos, &LevelPos{level, pos})
+		return true
+	}
+	player.Pos = pos
+	level.LastEvent = Portal
+	level.resetVisibility(player)
+	env.game.questMove(player)
+	env.game.runScripts(player, "step", "", pos)
+	return true
+}
+
+// Runes set-tile can change a tile into. Doors and stairs go over the floor.
+var scriptTiles = map[string]bool{
+	string(StoneWall):  false,
+	string(DirtFloor):  false,
+	string(ClosedDoor): true,
+	string(OpenDoor):   true,
+	string(SecretDoor): true,
+}
+
+// (set-tile x y "/") changes the map, eg. to open a door
+func scriptSetTile(env *scriptEnv, args []interface{}) interface{} {
+	level := env.player.level
+	pos := env.scriptPos(args[0], args[1])
+	r := scriptString(args[2])
+	overlay, ok := scriptTiles[r]
+	if !ok {
+		panic(scriptError("can't set a tile to " + r))
+	}
+	if level.Portals[pos] != nil || isStair(level, pos) {
+		panic(scriptError("can't change stairs or portals"))
+	}
+	tile := &level.Map[pos.Y][pos.X]
+	if overlay {
+		if tile.Rune == StoneWall || tile.Rune == Blank {
+			tile.Rune = DirtFloor
+		}
+		tile.OverlayRune = []rune(r)[0]
+	} else {
+		if r == string(StoneWall) && (level.Monsters[pos] != nil || level.NPCs[pos] != nil || level.playerAt(pos) != nil) {
+			return false // Don't wall anyone in
+		}
+		tile.Rune = []rune(r)[0]
+		tile.OverlayRune = Blank
+	}
+	level.updateVisibility()
+	return true
+}
+
+// (give Sword) puts a new item in the player's inventory
+func scriptGive(env *scriptEnv, args []interface{}) interface{} {
+	player := env.player
+	item := NewItem(scriptString(args[0]), player.Pos)
+	if item == nil {
+		panic(scriptError("no item called " + scriptString(args[0])))
+	}
+	player.Items = append(player.Items, item)
+	player.level.AddEvent(player.Name + " received " + item.Name)
+	return true
+}
+
+// (flag name) and (set-flag name) share flags with dialogue, so either can check what the other did
+func scriptFlag(env *scriptEnv, args []interface{}) interface{} {
+	return env.player.Flags[scriptString(args[0])]
+}
+
+func scriptSetFlag(env *scriptEnv, args []interface{}) interface{} {
+	env.player.Flags[scriptString(args[0])] = true
+	return true
+}
+
+// (has Helmet) checks the player's inventory
+func scriptHas(env *scriptEnv, args []interface{}) interface{} {
+	return env.player.findItem(scriptString(args[0])) != nil
+}
+
+func scriptNot(env *scriptEnv, args []interface{}) interface{} {
+	return !truthy(args[0])
+}
+
+// (level) is the name of the level the player is on
+func scriptLevel(env *scriptEnv, args []interface{}) interface{} {
+	return env.player.level.Name
+}
+
+// (= a b) compares numbers and names alike
+func scriptEquals(env *scriptEnv, args []interface{}) interface{} {
+	return scriptString(args[0]) == scriptString(args[1])
+}
+
+func scriptPlus(env *scriptEnv, args []interface{}) interface{} {
+	return scriptInt(args[0]) + scriptInt(args[1])
+}
+
+func scriptMinus(env *scriptEnv, args []interface{}) interface{} {
+	return scriptInt(args[0]) - scriptInt(args[1])
+}
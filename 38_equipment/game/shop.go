@@ -0,0 +1,103 @@
+package game
+
+import "strconv"
+
+const restockTurns = 200 // Turns before a merchant replaces what they've sold
+
+// Shop is a merchant's stock of items for sale
+type Shop struct {
+	Stock       []*Item
+	wares       []string // Names of items the merchant always brings back
+	lastRestock int
+}
+
+// NewMerchant spawns an NPC that trades items for gold
+func NewMerchant(p Pos) *NPC {
+	npc := &NPC{
+		Character: Character{
+			Entity: Entity{
+				Pos:  p,
+				Name: "Merchant",
+				Rune: 'M',
+			},
+			Hitpoints:    50,
+			MaxHitpoints: 50,
+			SightRange:   10,
+		},
+		Shop: &Shop{wares: []string{"Sword", "Helmet", "Helmet"}},
+	}
+	npc.Shop.restock(0)
+	return npc
+}
+
+// restock puts back any wares the merchant has run out of
+func (shop *Shop) restock(turn int) {
+	shop.lastRestock = turn
+	have := make(map[string]int)
+	for _, item := range shop.Stock {
+		have[item.Name]++
+	}
+	for _, name := range shop.wares {
+		if have[name] > 0 {
+			have[name]--
+			continue
+		}
+		shop.Stock = append(shop.Stock, NewItem(name, Pos{}))
+	}
+}
+
+// SellPrice is what a merchant will pay for an item
+func (item *Item) SellPrice() int {
+	return item.Value / 2
+}
+
+// Trade opens the merchant's shop for the player
+func (game *Game) Trade(player *Player, npc *NPC) {
+	shop := npc.Shop
+	if game.Turn-shop.lastRestock >= restockTurns {
+		shop.restock(game.Turn)
+	}
+	player.Trading = npc
+}
+
+// Buy moves an item from the merchant to the player, if they can afford it
+func (game *Game) Buy(player *Player, itemToBuy *Item) {
+	level := player.level
+	if player.Trading == nil {
+		return
+	}
+	shop := player.Trading.Shop
+	for i, item := range shop.Stock {
+		if item == itemToBuy {
+			if player.Gold < item.Value {
+				level.AddEvent(player.Name + " can't afford " + item.Name)
+				return
+			}
+			player.Gold -= item.Value
+			shop.Stock = append(shop.Stock[:i], shop.Stock[i+1:]...)
+			player.Items = append(player.Items, item)
+			level.AddEvent(player.Name + " bought 1x " + item.Name + " for " + strconv.Itoa(item.Value) + " gold")
+			return
+		}
+	}
+	// Another window may have bought it first
+	level.AddEvent("That item is no longer for sale")
+}
+
+// Sell moves an item from the player to the merchant
+func (game *Game) Sell(player *Player, itemToSell *Item) {
+	level := player.level
+	if player.Trading == nil {
+		return
+	}
+	for i, item := range player.Items {
+		if item == itemToSell {
+			player.Items = append(player.Items[:i], player.Items[i+1:]...)
+			player.Gold += item.SellPrice()
+			player.Trading.Shop.Stock = append(player.Trading.Shop.Stock, item)
+			level.AddEvent(player.Name + " sold 1x " + item.Name + " for " + strconv.Itoa(item.SellPrice()) + " gold")
+			return
+		}
+	}
+	level.AddEvent(player.Name + " doesn't have that anymore")
+}
@@ -0,0 +1,124 @@
+package game
+
+import "math/rand"
+
+const (
+	maxWanderSteps = 50  // Don't walk monsters around forever after a long absence
+	regenTurns     = 10  // Turns for a monster to heal 1 hitpoint
+	respawnTurns   = 100 // Turns between new monsters wandering in
+)
+
+// simulate roughly catches a level up on the turns it spent without the player
+func (level *Level) simulate(turns int) {
+	if turns <= 0 {
+		return
+	}
+
+	// Wander around aimlessly, since there is nobody to chase
+	steps := turns
+	if steps > maxWanderSteps {
+		steps = maxWanderSteps
+	}
+	for _, monster := range level.Monsters {
+		for i := 0; i < steps; i++ {
+			monster.wander(level)
+		}
+	}
+
+	// Lick wounds
+	heal := turns / regenTurns
+	for _, monster := range level.Monsters {
+		monster.Hitpoints += heal
+		if monster.Hitpoints > monster.MaxHitpoints {
+			monster.Hitpoints = monster.MaxHitpoints
+		}
+	}
+
+	// Refill the level up to the number of monsters it started with
+	respawns := turns / respawnTurns
+	for i := 0; i < respawns && len(level.Monsters) < level.monsterCap; i++ {
+		pos, ok := level.randomSpawnPos()
+		if !ok {
+			break
+		}
+		level.Monsters[pos] = newRandomMonster(pos, level.Depth)
+	}
+}
+
+// wander takes a single random step
+func (m *Monster) wander(level *Level) {
+	neighbors := getNeighbors(level, m.Pos)
+	if len(neighbors) == 0 {
+		return
+	}
+	to := neighbors[rand.Intn(len(neighbors))]
+	if level.Portals[to] != nil || level.playerAt(to) != nil {
+		return // Stay away from the way the player will come back in
+	}
+	delete(level.Monsters, m.Pos)
+	level.Monsters[to] = m
+	m.Pos = to
+}
+
+// randomSpawnPos finds a free floor tile away from every player
+func (level *Level) randomSpawnPos() (Pos, bool) {
+	for attempts := 0; attempts < 100; attempts++ {
+		pos := Pos{rand.Intn(len(level.Map[0])), rand.Intn(len(level.Map))}
+		if !canWalk(level, pos) || level.Portals[pos] != nil || level.Traps[pos] != nil {
+			continue
+		}
+		if level.nearAnyPlayer(pos) {
+			continue
+		}
+		return pos, true
+	}
+	return Pos{}, false
+}
+
+// nearAnyPlayer checks if pos is within sight range of someone on the level
+func (level *Level) nearAnyPlayer(pos Pos) bool {
+	for _, player := range level.Players {
+		xDist := pos.X - player.X
+		yDist := pos.Y - player.Y
+		if xDist*xDist+yDist*yDist <= player.SightRange*player.SightRange {
+			return true
+		}
+	}
+	return false
+}
+
+// followThroughPortal brings monsters standing next to the portal along with the player
+func (level *Level) followThroughPortal(from *Level, portal Pos, player *Player) {
+	if from == level {
+		return
+	}
+	for y := portal.Y - 1; y <= portal.Y+1; y++ {
+		for x := portal.X - 1; x <= portal.X+1; x++ {
+			monster, exists := from.Monsters[Pos{x, y}]
+			if !exists {
+				continue
+			}
+			to, ok := level.freeTileNear(player.Pos)
+			if !ok {
+				return
+			}
+			delete(from.Monsters, monster.Pos)
+			monster.Pos = to
+			level.Monsters[to] = monster
+			level.AddEvent(monster.Name + " followed " + player.Name)
+		}
+	}
+}
+
+// freeTileNear finds an empty walkable tile touching pos, with nobody standing on it
+func (level *Level) freeTileNear(pos Pos) (Pos, bool) {
+	for y := pos.Y - 1; y <= pos.Y+1; y++ {
+		for x := pos.X - 1; x <= pos.X+1; x++ {
+			p := Pos{x, y}
+			if p != pos && canWalk(level, p) && level.Portals[p] == nil && level.playerAt(p) == nil {
+				return p, true
+			}
+		}
+	}
+	return Pos{}, false
+}
@@ -0,0 +1,124 @@
+package game
+
+// UIs draw levels on their own goroutines while the game carries on, so every
+// UI is sent its own deep copy of the level instead of the one the game changes.
+// Items are the exception: they never change once made, and UIs send the same
+// pointers back in their inputs, so copies share them.
+
+// snapshot copies the player's level as the player sees it
+func (player *Player) snapshot() *Level {
+	level := player.level
+	snap := *level
+	snap.Player = player.copy()
+
+	seen := player.seenOn(level)
+	snap.Map = make([][]Tile, len(level.Map))
+	for y, row := range level.Map {
+		snap.Map[y] = make([]Tile, len(row))
+		for x, tile := range row {
+			pos := Pos{x, y}
+			tile.Visible = player.visible[pos]
+			tile.Seen = seen[pos]
+			snap.Map[y][x] = tile
+		}
+	}
+
+	snap.Players = make([]*Player, len(level.Players))
+	for i, p := range level.Players {
+		if p == player {
+			snap.Players[i] = snap.Player
+		} else {
+			snap.Players[i] = p.copy()
+		}
+	}
+
+	snap.Monsters = make(map[Pos]*Monster, len(level.Monsters))
+	for pos, monster := range level.Monsters {
+		m := *monster
+		m.Character = monster.Character.copy()
+		m.origin = monster
+		snap.Monsters[pos] = &m
+	}
+
+	snap.NPCs = make(map[Pos]*NPC, len(level.NPCs))
+	for pos, npc := range level.NPCs {
+		snap.NPCs[pos] = npc.copy()
+	}
+	// Point the player at the copied NPCs, so nothing leads back into the game
+	if player.Conversation != nil {
+		snap.Player.Conversation = &Conversation{snap.NPCs[player.Conversation.NPC.Pos], player.Conversation.Node}
+	}
+	if player.Trading != nil {
+		snap.Player.Trading = snap.NPCs[player.Trading.Pos]
+	}
+
+	snap.Items = make(map[Pos][]*Item, len(level.Items))
+	for pos, items := range level.Items {
+		snap.Items[pos] = append([]*Item{}, items...)
+	}
+
+	snap.Portals = make(map[Pos]*LevelPos, len(level.Portals))
+	for pos, levelAndPos := range level.Portals {
+		snap.Portals[pos] = &LevelPos{nil, levelAndPos.Pos} // Other levels aren't part of the snapshot
+	}
+
+	snap.Traps = make(map[Pos]*Trap, len(level.Traps))
+	for pos, trap := range level.Traps {
+		t := *trap
+		snap.Traps[pos] = &t
+	}
+
+	snap.Debug = make(map[Pos]bool, len(level.Debug))
+	for pos, debug := range level.Debug {
+		snap.Debug[pos] = debug
+	}
+
+	snap.Events = append([]string{}, level.Events...)
+	snap.Combats = append([]Combat{}, level.Combats...)
+	return &snap
+}
+
+// copy gives a character its own inventory, so picking things up doesn't change older copies
+func (c Character) copy() Character {
+	c.Items = append([]*Item{}, c.Items...)
+	return c
+}
+
+// copy makes a player for a snapshot, leaving out what only the game needs.
+// Conversation and Trading point at NPCs, so snapshot fills them in.
+func (player *Player) copy() *Player {
+	p := &Player{
+		Character: player.Character.copy(),
+		Flags:     make(map[string]bool, len(player.Flags)),
+		Gold:      player.Gold,
+		Light:     player.Light,
+		Stats:     player.Stats.copy(),
+		Route:     append([]Pos{}, player.Route...), // So the UI knows to keep traveling
+	}
+	p.Achievements = append(p.Achievements, player.Achievements...)
+	for flag, set := range player.Flags {
+		p.Flags[flag] = set
+	}
+	for _, quest := range player.Quests {
+		q := *quest
+		q.Objectives = make([]*Objective, len(quest.Objectives))
+		for i, objective := range quest.Objectives {
+			o := *objective
+			q.Objectives[i] = &o
+		}
+		p.Quests = append(p.Quests, &q)
+	}
+	return p
+}
+
+// copy makes an NPC for a snapshot, with its own shop stock
+func (npc *NPC) copy() *NPC {
+	n := *npc
+	n.Character = npc.Character.copy()
+	if npc.Shop != nil {
+		shop := *npc.Shop
+		shop.Stock = append([]*Item{}, npc.Shop.Stock...)
+		n.Shop = &shop
+	}
+	return &n
+}
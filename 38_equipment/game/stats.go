@@ -0,0 +1,468 @@
+package game
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	morgueDir     = "morgue"
+	highScoreFile = "highscores.json"
+	highScores    = 10 // How many runs the table keeps
+)
+
+// Stats counts what a player has done this run
+type Stats struct {
+	Turns         int            // Inputs the player has sent
+	Steps         int            // Tiles walked
+	Kills         map[string]int // Monster name to how many the player killed
+	DamageDealt   int
+	DamageTaken   int
+	ItemsPickedUp int
+	LevelsVisited []string // In the order they were first visited
+	Deepest       int      // Depth of the deepest level visited
+}
+
+func newStats() Stats {
+	return Stats{Kills: make(map[string]int)}
+}
+
+// copy gives a snapshot its own kill counts and levels
+func (stats Stats) copy() Stats {
+	kills := make(map[string]int, len(stats.Kills))
+	for name, count := range stats.Kills {
+		kills[name] = count
+	}
+	stats.Kills = kills
+	stats.LevelsVisited = append([]string{}, stats.LevelsVisited...)
+	return stats
+}
+
+// TotalKills adds up kills of every kind of monster
+func (stats *Stats) TotalKills() int {
+	total := 0
+	for _, count := range stats.Kills {
+		total += count
+	}
+	return total
+}
+
+// visit remembers a level the first time the player sets foot on it
+func (stats *Stats) visit(level *Level) {
+	for _, name := range stats.LevelsVisited {
+		if name == level.Name {
+			return
+		}
+	}
+	stats.LevelsVisited = append(stats.LevelsVisited, level.Name)
+	if level.Depth > stats.Deepest {
+		stats.Deepest = level.Depth
+	}
+}
+
+// Achievement is earned the first time all of its rules hold for a player's stats
+type Achievement struct {
+	ID          string
+	Name        string
+	Description string
+	rules       []achievementRule
+}
+
+// achievementRule is a stat that has to reach count, eg. "kills Rat 10" or "depth 3"
+type achievementRule struct {
+	stat  string
+	name  string // Monster name for kills, "any" for all of them
+	count int
+}
+
+// loadAchievements reads achievement definitions from file, in the order they should be listed
+//
+// Each achievement starts with @id and is followed by one keyword per line:
+//
+//	name Rat Catcher
+//	text Kill 10 rats.
+//	kills Rat 10
+//	kills any 50
+//	steps 1000
+//	turns 500
+//	dealt 300
+//	taken 200
+//	items 10
+//	levels 4
+//	depth 3
+func loadAchievements() []*Achievement {
+	achievements := make([]*Achievement, 0)
+	data := files.MustRead("game/achievements.txt")
+
+	var current *Achievement
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '@' {
+			current = &Achievement{ID: strings.TrimSpace(line[1:])}
+			achievements = append(achievements, current)
+			continue
+		}
+		if current == nil {
+			panic("Achievement line before first achievement: " + line)
+		}
+		words := strings.Fields(line)
+		rest := strings.TrimSpace(line[len(words[0]):])
+		switch words[0] {
+		case "name":
+			current.Name = rest
+		case "text":
+			current.Description = rest
+		case "kills":
+			if len(words) != 3 {
+				panic("Invalid achievement line: " + line)
+			}
+			current.rules = append(current.rules, achievementRule{words[0], words[1], atoi(words[2])})
+		case "steps", "turns", "dealt", "taken", "items", "levels", "depth":
+			if len(words) != 2 {
+				panic("Invalid achievement line: " + line)
+			}
+			current.rules = append(current.rules, achievementRule{words[0], "", atoi(words[1])})
+		default:
+			panic("Invalid achievement line: " + line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		panic(err)
+	}
+	return achievements
+}
+
+// value looks up the stat a rule is about
+func (rule achievementRule) value(stats *Stats) int {
+	switch rule.stat {
+	case "kills":
+		if rule.name == "any" {
+			return stats.TotalKills()
+		}
+		return stats.Kills[rule.name]
+	case "steps":
+		return stats.Steps
+	case "turns":
+		return stats.Turns
+	case "dealt":
+		return stats.DamageDealt
+	case "taken":
+		return stats.DamageTaken
+	case "items":
+		return stats.ItemsPickedUp
+	case "levels":
+		return len(stats.LevelsVisited)
+	case "depth":
+		return stats.Deepest
+	}
+	return 0
+}
+
+// HasAchievement says whether the player has earned an achievement yet
+func (player *Player) HasAchievement(id string) bool {
+	for _, earned := range player.Achievements {
+		if earned == id {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAchievements hands out any achievements the player has just earned
+func (game *Game) checkAchievements(player *Player) {
+	for _, achievement := range game.Achievements {
+		if player.HasAchievement(achievement.ID) {
+			continue
+		}
+		earned := true
+		for _, rule := range achievement.rules {
+			if rule.value(&player.Stats) < rule.count {
+				earned = false
+				break
+			}
+		}
+		if earned {
+			player.Achievements = append(player.Achievements, achievement.ID)
+			player.level.AddEvent(player.Name + " earned " + achievement.Name + "!")
+		}
+	}
+}
+
+// die ends the player's run at the end of the turn, remembering what did it
+func (player *Player) die(cause string) {
+	if player.ended == "" {
+		player.ended = "Killed by " + cause + " on " + player.level.Name + " (depth " + strconv.Itoa(player.level.Depth) + ")"
+	}
+}
+
+// completedEveryQuest is how a player wins
+func (game *Game) completedEveryQuest(player *Player) bool {
+	if len(game.QuestDefs) == 0 || len(player.Quests) < len(game.QuestDefs) {
+		return false
+	}
+	for _, quest := range player.Quests {
+		if !quest.Done {
+			return false
+		}
+	}
+	return true
+}
+
+// endRuns writes a morgue file and a high score for anyone who died or won
+// this turn, then closes their window so everyone else can keep playing
+func (game *Game) endRuns() {
+	for i := 0; i < len(game.Players); {
+		player := game.Players[i]
+		if player.ended == "" {
+			game.checkAchievements(player) // The dead don't earn anything
+		}
+		if player.ended == "" && game.completedEveryQuest(player) {
+			player.won = true
+			player.ended = "Completed every quest"
+		}
+		if player.ended == "" {
+			i++
+			continue
+		}
+
+		score := game.score(player)
+		morgue := game.writeMorgue(player, score)
+		rank := addHighScore(HighScore{player.Name, score, player.Stats.Turns, player.Stats.Deepest, player.ended, time.Now()})
+		fmt.Println(player.Name + ": " + player.ended + ". Scored " + strconv.Itoa(score) + ", morgue file written to " + morgue)
+		if rank > 0 {
+			fmt.Println("That's number " + strconv.Itoa(rank) + " on the high score table")
+		}
+
+		// Same as the player closing their window, which moves everyone after them down one
+		lchan := game.LevelChans[i]
+		close(lchan)
+		game.closeFeed(lchan)
+		game.removePlayerFor(lchan)
+	}
+}
+
+// score rewards fighting and going deep, with a bonus for winning
+func (game *Game) score(player *Player) int {
+	score := player.Gold + 25*player.Stats.TotalKills() + 100*player.Stats.Deepest + 50*len(player.Achievements)
+	for _, quest := range player.Quests {
+		if quest.Done {
+			score += 100
+		}
+	}
+	if player.won {
+		score += 1000
+	}
+	return score
+}
+
+// writeMorgue writes a human readable account of the run, and returns where it went
+func (game *Game) writeMorgue(player *Player, score int) string {
+	err := os.MkdirAll(morgueDir, 0755)
+	if err != nil {
+		panic(err)
+	}
+	name := strings.Replace(player.Name, " ", "_", -1) + "-" + time.Now().Format("20060102-150405") + ".txt"
+	path := filepath.Join(morgueDir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	defer w.Flush()
+
+	stats := &player.Stats
+	fmt.Fprintf(w, "%s\n%s after %d turns.\nScore: %d\n\n", player.Name, player.ended, stats.Turns, score)
+
+	fmt.Fprintf(w, "== Character ==\n")
+	fmt.Fprintf(w, "Hitpoints: %d/%d\nStrength:  %d\nSpeed:     %.1f\nSight:     %d\nGold:      %d\n",
+		player.Hitpoints, player.MaxHitpoints, player.Strength, player.Speed, player.SightRange, player.Gold)
+	fmt.Fprintf(w, "Helmet:    %s\nWeapon:    %s\n", itemName(player.Helmet), itemName(player.Weapon))
+	items := make([]string, len(player.Items))
+	for i, item := range player.Items {
+		items[i] = item.Name
+	}
+	if len(items) == 0 {
+		items = append(items, "nothing")
+	}
+	fmt.Fprintf(w, "Inventory: %s\n\n", strings.Join(items, ", "))
+
+	fmt.Fprintf(w, "== Stats ==\n")
+	fmt.Fprintf(w, "Steps taken:     %d\nDamage dealt:    %d\nDamage taken:    %d\nItems picked up: %d\n",
+		stats.Steps, stats.DamageDealt, stats.DamageTaken, stats.ItemsPickedUp)
+	fmt.Fprintf(w, "Deepest level:   %d\nLevels visited:  %s\n", stats.Deepest, strings.Join(stats.LevelsVisited, ", "))
+	fmt.Fprintf(w, "Kills:           %d\n", stats.TotalKills())
+	names := make([]string, 0, len(stats.Kills))
+	for name := range stats.Kills {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-14s %d\n", name, stats.Kills[name])
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "== Achievements ==\n")
+	if len(player.Achievements) == 0 {
+		fmt.Fprintf(w, "None\n")
+	}
+	for _, achievement := range game.Achievements {
+		if player.HasAchievement(achievement.ID) {
+			fmt.Fprintf(w, "%s: %s\n", achievement.Name, achievement.Description)
+		}
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "== Quests ==\n")
+	if len(player.Quests) == 0 {
+		fmt.Fprintf(w, "None\n")
+	}
+	for _, quest := range player.Quests {
+		if quest.Done {
+			fmt.Fprintf(w, "%s (complete)\n", quest.Name)
+		} else {
+			fmt.Fprintf(w, "%s\n", quest.Name)
+		}
+	}
+	fmt.Fprintf(w, "\n")
+
+	level := player.level
+	fmt.Fprintf(w, "== Last messages ==\n")
+	for i := range level.Events {
+		event := level.Events[(level.EventPos+i)%len(level.Events)]
+		if event != "" {
+			fmt.Fprintf(w, "%s\n", event)
+		}
+	}
+	fmt.Fprintf(w, "\n")
+
+	// The level the run ended on first, then the rest in the order they were found
+	fmt.Fprintf(w, "== Map of %s ==\n", level.Name)
+	writeSeenMap(w, level, player.seenOn(level), player.Pos)
+	for _, name := range stats.LevelsVisited {
+		other := game.Levels[name]
+		if other == nil || other == level {
+			continue
+		}
+		fmt.Fprintf(w, "\n== Map of %s ==\n", name)
+		writeSeenMap(w, other, player.seenOn(other), Pos{-1, -1})
+	}
+	return path
+}
+
+func itemName(item *Item) string {
+	if item == nil {
+		return "none"
+	}
+	return item.Name
+}
+
+// writeSeenMap draws the tiles the player saw, trimmed to the part they explored
+func writeSeenMap(w *bufio.Writer, level *Level, seen map[Pos]bool, player Pos) {
+	minX, minY, maxX, maxY := len(level.Map[0]), len(level.Map), -1, -1
+	for pos := range seen {
+		if pos.X < minX {
+			minX = pos.X
+		}
+		if pos.Y < minY {
+			minY = pos.Y
+		}
+		if pos.X > maxX {
+			maxX = pos.X
+		}
+		if pos.Y > maxY {
+			maxY = pos.Y
+		}
+	}
+	for y := minY; y <= maxY; y++ {
+		line := make([]rune, 0, maxX-minX+1)
+		for x := minX; x <= maxX; x++ {
+			pos := Pos{x, y}
+			tile := level.Map[y][x]
+			switch {
+			case pos == player:
+				line = append(line, '@')
+			case !seen[pos] || tile.Rune == Blank:
+				line = append(line, ' ')
+			case tile.OverlayRune == SecretDoor:
+				line = append(line, StoneWall) // Nobody found it
+			case tile.OverlayRune != Blank:
+				line = append(line, tile.OverlayRune)
+			default:
+				line = append(line, tile.Rune)
+			}
+		}
+		w.WriteString(strings.TrimRight(string(line), " ") + "\n")
+	}
+}
+
+// HighScore is one finished run on the high score table
+type HighScore struct {
+	Name  string
+	Score int
+	Turns int
+	Depth int
+	Cause string // How the run ended
+	When  time.Time
+}
+
+// LoadHighScores reads the high score table, best first
+func LoadHighScores() []HighScore {
+	scores := make([]HighScore, 0)
+	file, err := os.Open(highScoreFile)
+	if err != nil {
+		return scores // Nobody has finished a run yet
+	}
+	defer file.Close()
+	err = json.NewDecoder(file).Decode(&scores)
+	if err != nil {
+		panic(err)
+	}
+	return scores
+}
+
+// addHighScore puts a run on the table, and returns where it ranked, or 0 if it didn't make it
+func addHighScore(entry HighScore) int {
+	scores := append(LoadHighScores(), entry)
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+	rank := 0
+	for i := range scores {
+		if scores[i] == entry {
+			rank = i + 1
+			break
+		}
+	}
+	if len(scores) > highScores {
+		scores = scores[:highScores]
+	}
+	if rank > highScores {
+		rank = 0
+	}
+
+	file, err := os.Create(highScoreFile)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "\t") // So it can be read without the game
+	err = encoder.Encode(scores)
+	if err != nil {
+		panic(err)
+	}
+	return rank
+}
@@ -0,0 +1,136 @@
+package game
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// TrapType is a tagged union/discriminating union/sum type
+type TrapType int
+
+const (
+	// SpikeTrap damages whoever steps on it
+	SpikeTrap TrapType = iota
+	// TeleportTrap sends the player to a random floor tile
+	TeleportTrap
+	// AlarmTrap wakes up every monster on the level
+	AlarmTrap
+)
+
+// Trap is hidden until searched for or stepped on
+type Trap struct {
+	Typ TrapType
+	Entity
+	Hidden bool
+	damage int
+}
+
+// NewSpikeTrap is an instance of a spike trap
+func NewSpikeTrap(p Pos) *Trap {
+	return &Trap{
+		Typ: SpikeTrap,
+		Entity: Entity{
+			Pos:  p,
+			Name: "Spike Trap",
+			Rune: '^',
+		},
+		Hidden: true,
+		damage: 10,
+	}
+}
+
+// NewTeleportTrap is an instance of a teleport trap
+func NewTeleportTrap(p Pos) *Trap {
+	return &Trap{
+		Typ: TeleportTrap,
+		Entity: Entity{
+			Pos:  p,
+			Name: "Teleport Trap",
+			Rune: '~',
+		},
+		Hidden: true,
+	}
+}
+
+// NewAlarmTrap is an instance of an alarm trap
+func NewAlarmTrap(p Pos) *Trap {
+	return &Trap{
+		Typ: AlarmTrap,
+		Entity: Entity{
+			Pos:  p,
+			Name: "Alarm Trap",
+			Rune: '!',
+		},
+		Hidden: true,
+	}
+}
+
+// Search rolls a perception check for every tile surrounding the character
+func (level *Level) Search(character *Character) {
+	character.ActionPoints--
+	found := 0
+	for y := character.Y - 1; y <= character.Y+1; y++ {
+		for x := character.X - 1; x <= character.X+1; x++ {
+			pos := Pos{x, y}
+			if !inRange(level, pos) || pos == character.Pos {
+				continue
+			}
+			// Roll a d20 against perception for each hidden feature
+			if level.Map[y][x].OverlayRune == SecretDoor && rand.Intn(20) < character.Perception {
+				level.Map[y][x].OverlayRune = ClosedDoor // Secret doors turn into regular doors once found
+				level.AddEvent(character.Name + " found a secret door")
+				found++
+			}
+			trap, exists := level.Traps[pos]
+			if exists && trap.Hidden && rand.Intn(20) < character.Perception {
+				trap.Hidden = false
+				level.AddEvent(character.Name + " found a " + trap.Name)
+				found++
+			}
+		}
+	}
+	if found == 0 {
+		level.AddEvent(character.Name + " found nothing")
+	}
+}
+
+// triggerTrap springs the trap under the player, if any
+func (game *Game) triggerTrap(player *Player, pos Pos) {
+	level := player.level
+	trap, exists := level.Traps[pos]
+	if !exists {
+		return
+	}
+	trap.Hidden = false // Stepping on a trap reveals it
+	level.LastEvent = TrapTriggered
+	switch trap.Typ {
+	case SpikeTrap:
+		player.Hitpoints -= trap.damage
+		player.Stats.DamageTaken += trap.damage
+		level.AddEvent(player.Name + " stepped on a " + trap.Name + " for " + strconv.Itoa(trap.damage))
+		if player.Hitpoints <= 0 {
+			player.die("a " + trap.Name)
+		}
+	case TeleportTrap:
+		level.AddEvent(player.Name + " stepped on a " + trap.Name)
+		floors := make([]Pos, 0)
+		for y, row := range level.Map {
+			for x := range row {
+				p := Pos{x, y}
+				if p != pos && canWalk(level, p) && level.Traps[p] == nil && level.Portals[p] == nil && level.playerAt(p) == nil {
+					floors = append(floors, p)
+				}
+			}
+		}
+		if len(floors) > 0 {
+			player.Pos = floors[rand.Intn(len(floors))]
+			level.resetVisibility(player)
+		}
+	case AlarmTrap:
+		level.AddEvent(player.Name + " stepped on an " + trap.Name + "!")
+		// Every monster on the level gets a free turn to close in
+		for _, monster := range level.Monsters {
+			monster.ActionPoints += monster.Speed
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package game
+
+// Travel walks the player along an a* route one turn at a time. The UI sends
+// KeepTraveling for each step, so monsters still get their turns on the way.
+
+// Traveling is true while the player has steps left on a route
+func (player *Player) Traveling() bool {
+	return len(player.Route) > 0
+}
+
+// knownNeighbors is getNeighbors for a player, who can only plan through tiles they have seen
+// Closed doors are fine since walking into one opens it. Stairs, portals and known traps
+// would take us somewhere we didn't ask to go, so they can only be the goal.
+func (player *Player) knownNeighbors(level *Level, pos, goal Pos) []Pos {
+	seen := player.seenOn(level)
+	neighbors := make([]Pos, 0, 4)
+	dirs := []Pos{{pos.X - 1, pos.Y}, {pos.X + 1, pos.Y}, {pos.X, pos.Y - 1}, {pos.X, pos.Y + 1}}
+	for _, dir := range dirs {
+		if !inRange(level, dir) || !seen[dir] {
+			continue
+		}
+		t := level.Map[dir.Y][dir.X]
+		switch t.Rune {
+		case StoneWall, Blank:
+			continue
+		}
+		if t.OverlayRune == SecretDoor || level.NPCs[dir] != nil {
+			continue
+		}
+		if dir != goal {
+			trap := level.Traps[dir]
+			if isStair(level, dir) || level.Portals[dir] != nil || (trap != nil && !trap.Hidden) {
+				continue
+			}
+		}
+		neighbors = append(neighbors, dir)
+	}
+	return neighbors
+}
+
+// planRoute finds a way to goal through known tiles, without the tile we're standing on
+func (player *Player) planRoute(goal Pos) []Pos {
+	level := player.level
+	if !inRange(level, goal) || !player.seenOn(level)[goal] || goal == player.Pos {
+		return nil
+	}
+	path := astarWith(player.Pos, goal, func(pos Pos) []Pos {
+		return player.knownNeighbors(level, pos, goal)
+	})
+	if len(path) < 2 {
+		return nil
+	}
+	return path[1:]
+}
+
+// monsterInView returns a monster the player can see, or nil
+func (player *Player) monsterInView() *Monster {
+	for pos, monster := range player.level.Monsters {
+		if player.visible[pos] {
+			return monster
+		}
+	}
+	return nil
+}
+
+func (game *Game) startTravel(player *Player, goal Pos) {
+	level := player.level
+	if monster := player.monsterInView(); monster != nil {
+		level.AddEvent("Not with a " + monster.Name + " nearby")
+		return
+	}
+	route := player.planRoute(goal)
+	if route == nil {
+		level.AddEvent("You don't know a way there")
+		return
+	}
+	player.setRoute(route)
+	game.travelStep(player)
+}
+
+// setRoute starts a new route, remembering how things were so travelStep knows when to stop
+func (player *Player) setRoute(route []Pos) {
+	player.Route = route
+	player.routeLevel = player.level
+	player.routeEvents = player.level.EventPos
+	player.routeHP = player.Hitpoints
+}
+
+// stopTravel forgets the route, and stops exploring
+func (player *Player) stopTravel() {
+	player.Route = nil
+	player.exploring = false
+}
+
+// travelToStairs heads for whichever known stair is the shortest walk away
+func (game *Game) travelToStairs(player *Player) {
+	level := player.level
+	var best []Pos
+	for pos := range player.seenOn(level) {
+		if !isStair(level, pos) {
+			continue
+		}
+		route := player.planRoute(pos)
+		if route != nil && (best == nil || len(route) < len(best)) {
+			best = route
+		}
+	}
+	if best == nil {
+		level.AddEvent("You don't know where any stairs are")
+		return
+	}
+	game.startTravel(player, best[len(best)-1])
+}
+
+// travelStep takes the next step, unless something has happened since we set off
+func (game *Game) travelStep(player *Player) {
+	if !player.Traveling() {
+		return
+	}
+	level := player.level
+	if level != player.routeLevel {
+		player.stopTravel() // Took a portal
+		return
+	}
+	if player.Hitpoints < player.routeHP {
+		player.stopTravel()
+		level.AddEvent("You stop, you're hurt")
+		return
+	}
+	if level.EventPos != player.routeEvents {
+		player.stopTravel() // Something happened worth reading about
+		return
+	}
+	if monster := player.monsterInView(); monster != nil {
+		player.stopTravel()
+		level.AddEvent("You stop, a " + monster.Name + " comes into view")
+		return
+	}
+
+	next := player.Route[0]
+	wasClosed := level.Map[next.Y][next.X].OverlayRune == ClosedDoor
+	game.resolveMovement(player, next)
+	switch {
+	case player.level != level:
+		player.stopTravel() // We arrived on a stair or portal
+	case player.Pos == next:
+		player.Route = player.Route[1:]
+		if player.exploring {
+			game.exploreStep(player)
+		}
+	case wasClosed && level.Map[next.Y][next.X].OverlayRune == OpenDoor:
+		// We opened a door, walk through it next turn
+	default:
+		player.stopTravel()
+		level.AddEvent("Something is in the way")
+	}
+}
@@ -0,0 +1,338 @@
+package game
+
+import "reflect"
+
+// Update is what changed on a player's level since the last update their UI took.
+// Updates a UI doesn't get around to taking are merged into the next one, so
+// a slow UI skips ahead instead of holding up the game.
+type Update struct {
+	Full      *Level            // The whole level, when there is nothing to build on, eg. after changing levels
+	Tiles     map[Pos]Tile      // Tiles that changed, including whether they are visible or seen
+	Moves     []MonsterMove     // Monsters that walked somewhere else
+	Monsters  map[Pos]*Monster  // Monsters that appeared or changed, eg. got hurt
+	Deaths    []Pos             // Monsters that are gone
+	Items     map[Pos][]*Item   // Piles of items that changed, empty once picked up
+	NPCs      map[Pos]*NPC      // NPCs whose shop changed
+	Traps     map[Pos]*Trap     // Traps that were found or set off
+	Portals   map[Pos]*LevelPos // Stairs that now lead somewhere
+	Lights    map[Pos]*Light    // Every torch, when the map was reloaded with different ones
+	Player    *Player           // The player's stats and inventory, when they changed
+	Players   []*Player         // Everyone on the level, when anyone moved
+	Events    []string          // The whole event log, when there is something new
+	Combats   []Combat          // The last few attacks, when there was a new one
+	EventPos  int
+	LastEvent GameEvent
+	LastTurn  int
+}
+
+// MonsterMove is a monster walking from one tile to another
+type MonsterMove struct {
+	From, To Pos
+}
+
+// feed sends one UI updates instead of whole levels
+type feed struct {
+	updates chan *Update
+	last    *Level // What the UI will have once it takes the waiting update
+	before  *Level // What the UI had before the waiting update
+}
+
+// Updates gives a UI a stream of changes to its player's level, instead of
+// whole levels on lchan. Call it before Run. The first update is always Full.
+func (game *Game) Updates(lchan chan *Level) chan *Update {
+	f := &feed{updates: make(chan *Update, 1)}
+	game.feeds[lchan] = f
+	return f.updates
+}
+
+// publishAll sends game state updates, each UI gets its own copy to read while we carry on
+func (game *Game) publishAll() {
+	for i, lchan := range game.LevelChans {
+		game.publish(lchan, game.Players[i].snapshot())
+	}
+}
+
+// publish hands a UI its latest level without waiting for the UI to read it
+func (game *Game) publish(lchan chan *Level, snap *Level) {
+	// We're the only sender, so once we've taken back what the UI hasn't read there is always room
+	select {
+	case <-lchan:
+	default:
+	}
+	lchan <- snap
+
+	f := game.feeds[lchan]
+	if f == nil {
+		return
+	}
+	from := f.last
+	select {
+	case <-f.updates:
+		from = f.before // The UI never saw it, so build on what it had before
+	default:
+	}
+	f.before = from
+	f.last = snap
+	f.updates <- Diff(from, snap)
+}
+
+// closeFeed stops sending updates for a closed window
+func (game *Game) closeFeed(lchan chan *Level) {
+	if f := game.feeds[lchan]; f != nil {
+		close(f.updates)
+		delete(game.feeds, lchan)
+	}
+}
+
+// Diff works out the update that turns one snapshot into another, UIs also use it to animate what moved
+func Diff(from, to *Level) *Update {
+	update := &Update{LastEvent: to.LastEvent, LastTurn: to.LastTurn, EventPos: to.EventPos}
+	if from == nil || from.Name != to.Name || len(from.Map) != len(to.Map) {
+		update.Full = to
+		return update
+	}
+
+	for y, row := range to.Map {
+		for x, tile := range row {
+			if from.Map[y][x] != tile {
+				if update.Tiles == nil {
+					update.Tiles = make(map[Pos]Tile)
+				}
+				update.Tiles[Pos{x, y}] = tile
+			}
+		}
+	}
+
+	// Follow monsters by the game's monster they were copied from
+	wasAt := make(map[*Monster]*Monster, len(from.Monsters))
+	for _, monster := range from.Monsters {
+		wasAt[monster.origin] = monster
+	}
+	for pos, monster := range to.Monsters {
+		before := wasAt[monster.origin]
+		delete(wasAt, monster.origin)
+		if before != nil && before.Pos != pos {
+			update.Moves = append(update.Moves, MonsterMove{before.Pos, pos})
+		}
+		if before == nil || before.Hitpoints != monster.Hitpoints || before.Rune != monster.Rune {
+			if update.Monsters == nil {
+				update.Monsters = make(map[Pos]*Monster)
+			}
+			update.Monsters[pos] = monster
+		}
+	}
+	for _, monster := range wasAt {
+		update.Deaths = append(update.Deaths, monster.Pos)
+	}
+
+	for pos, items := range to.Items {
+		if !sameItems(from.Items[pos], items) {
+			if update.Items == nil {
+				update.Items = make(map[Pos][]*Item)
+			}
+			update.Items[pos] = items
+		}
+	}
+	for pos, items := range from.Items {
+		if _, exists := to.Items[pos]; !exists && len(items) > 0 {
+			if update.Items == nil {
+				update.Items = make(map[Pos][]*Item)
+			}
+			update.Items[pos] = nil
+		}
+	}
+
+	for pos, npc := range to.NPCs {
+		before := from.NPCs[pos]
+		if before == nil || before.Shop != nil && !sameItems(before.Shop.Stock, npc.Shop.Stock) {
+			if update.NPCs == nil {
+				update.NPCs = make(map[Pos]*NPC)
+			}
+			update.NPCs[pos] = npc
+		}
+	}
+
+	for pos, trap := range to.Traps {
+		if before := from.Traps[pos]; before == nil || *before != *trap {
+			if update.Traps == nil {
+				update.Traps = make(map[Pos]*Trap)
+			}
+			update.Traps[pos] = trap
+		}
+	}
+
+	for pos, levelAndPos := range to.Portals {
+		if before := from.Portals[pos]; before == nil || before.Pos != levelAndPos.Pos {
+			if update.Portals == nil {
+				update.Portals = make(map[Pos]*LevelPos)
+			}
+			update.Portals[pos] = levelAndPos
+		}
+	}
+
+	if !sameLights(from.Lights, to.Lights) {
+		update.Lights = to.Lights
+	}
+
+	if !reflect.DeepEqual(from.Player, to.Player) {
+		update.Player = to.Player
+	}
+	if !samePlayers(from.Players, to.Players) {
+		update.Players = to.Players
+	}
+	if from.EventPos != to.EventPos || !reflect.DeepEqual(from.Events, to.Events) {
+		update.Events = to.Events
+	}
+	if !reflect.DeepEqual(from.Combats, to.Combats) {
+		update.Combats = to.Combats
+	}
+	return update
+}
+
+func sameItems(a, b []*Item) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameLights(a, b map[Pos]*Light) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for pos, light := range a {
+		if b[pos] == nil || *b[pos] != *light {
+			return false
+		}
+	}
+	return true
+}
+
+func samePlayers(a, b []*Player) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Entity != b[i].Entity || a[i].Hitpoints != b[i].Hitpoints {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply builds the level after the update, leaving the one it is given alone,
+// so a UI can keep drawing the old level while it works
+func (update *Update) Apply(level *Level) *Level {
+	if update.Full != nil {
+		return update.Full
+	}
+	next := *level
+	next.LastEvent = update.LastEvent
+	next.LastTurn = update.LastTurn
+	next.EventPos = update.EventPos
+
+	if len(update.Tiles) > 0 {
+		next.Map = append([][]Tile{}, level.Map...)
+		copied := make(map[int]bool)
+		for pos, tile := range update.Tiles {
+			if !copied[pos.Y] {
+				next.Map[pos.Y] = append([]Tile{}, level.Map[pos.Y]...)
+				copied[pos.Y] = true
+			}
+			next.Map[pos.Y][pos.X] = tile
+		}
+	}
+
+	if len(update.Moves) > 0 || len(update.Monsters) > 0 || len(update.Deaths) > 0 {
+		next.Monsters = make(map[Pos]*Monster, len(level.Monsters))
+		for pos, monster := range level.Monsters {
+			next.Monsters[pos] = monster
+		}
+		for _, pos := range update.Deaths {
+			delete(next.Monsters, pos)
+		}
+		// Pick everyone up before putting them down, since one may step where another was
+		moved := make([]*Monster, len(update.Moves))
+		for i, move := range update.Moves {
+			moved[i] = next.Monsters[move.From]
+			delete(next.Monsters, move.From)
+		}
+		for i, move := range update.Moves {
+			if moved[i] != nil {
+				m := *moved[i]
+				m.Pos = move.To
+				next.Monsters[move.To] = &m
+			}
+		}
+		for pos, monster := range update.Monsters {
+			next.Monsters[pos] = monster
+		}
+	}
+
+	if len(update.Items) > 0 {
+		next.Items = make(map[Pos][]*Item, len(level.Items))
+		for pos, items := range level.Items {
+			next.Items[pos] = items
+		}
+		for pos, items := range update.Items {
+			if len(items) == 0 {
+				delete(next.Items, pos)
+			} else {
+				next.Items[pos] = items
+			}
+		}
+	}
+
+	if len(update.NPCs) > 0 {
+		next.NPCs = make(map[Pos]*NPC, len(level.NPCs))
+		for pos, npc := range level.NPCs {
+			next.NPCs[pos] = npc
+		}
+		for pos, npc := range update.NPCs {
+			next.NPCs[pos] = npc
+		}
+	}
+
+	if len(update.Traps) > 0 {
+		next.Traps = make(map[Pos]*Trap, len(level.Traps))
+		for pos, trap := range level.Traps {
+			next.Traps[pos] = trap
+		}
+		for pos, trap := range update.Traps {
+			next.Traps[pos] = trap
+		}
+	}
+
+	if len(update.Portals) > 0 {
+		next.Portals = make(map[Pos]*LevelPos, len(level.Portals))
+		for pos, levelAndPos := range level.Portals {
+			next.Portals[pos] = levelAndPos
+		}
+		for pos, levelAndPos := range update.Portals {
+			next.Portals[pos] = levelAndPos
+		}
+	}
+
+	if update.Lights != nil {
+		next.Lights = update.Lights
+	}
+
+	if update.Player != nil {
+		next.Player = update.Player
+	}
+	if update.Players != nil {
+		next.Players = update.Players
+	}
+	if update.Events != nil {
+		next.Events = update.Events
+	}
+	if update.Combats != nil {
+		next.Combats = update.Combats
+	}
+	return &next
+}
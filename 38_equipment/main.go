@@ -3,18 +3,58 @@ package main
 // https://youtu.be/Jy919y3ezOI?t=1346
 
 import (
+	"flag"
+	"runtime"
+	"strings"
+
+	"github.com/maxproske/games-with-go/38_equipment/assets"
 	"github.com/maxproske/games-with-go/38_equipment/game"
-	"github.com/maxproske/games-with-go/38_equipment/ui2d"
+	"github.com/maxproske/games-with-go/38_equipment/netplay"
+	_ "github.com/maxproske/games-with-go/38_equipment/ui2d"  // Registers the sdl frontend
+	_ "github.com/maxproske/games-with-go/38_equipment/uitty" // Registers the tty frontend
+	_ "github.com/maxproske/games-with-go/38_equipment/uiweb" // Registers the web frontend
 )
 
 func main() {
-	// Make new game
-	game := game.NewGame(1)
-	go func() {
-		game.Run()
-	}()
-
-	// Make our UI
-	ui := ui2d.NewUI(game.InputChan, game.LevelChans[0])
-	ui.Run()
+	connect := flag.String("connect", "", "address of a game server to play on, eg. localhost:7777")
+	players := flag.Int("players", 1, "number of frontends, each with its own hero")
+	frontend := flag.String("frontend", "sdl", "how to play, one of: "+strings.Join(game.FrontendNames(), ", "))
+	flag.BoolVar(&game.AutoPickup, "autopickup", game.AutoPickup, "pick up items while auto-exploring")
+	flag.StringVar(&assets.Dir, "assets", "", "directory laid out like this one to load maps and art from instead of the built in ones, eg. . to edit them while playing")
+	flag.Parse()
+
+	if *connect != "" {
+		// Play on a server instead of running the game ourselves
+		client, err := netplay.Dial(*connect)
+		if err != nil {
+			panic(err)
+		}
+		ui, err := game.NewFrontend(*frontend, client.Channels())
+		if err != nil {
+			panic(err)
+		}
+		ui.Run()
+		return
+	}
+
+	// Make new game, with a frontend and a hero for each player
+	g := game.NewGame(*players)
+
+	// Make our UIs, the same way as 26_multithread_ui
+	uis := make([]game.Frontend, *players)
+	for i := range uis {
+		ui, err := game.NewFrontend(*frontend, g.Channels(i))
+		if err != nil {
+			panic(err)
+		}
+		uis[i] = ui
+	}
+	for _, ui := range uis {
+		go func(ui game.Frontend) {
+			runtime.LockOSThread() // Goroutines must stay on the same thread for the window to draw and handle input
+			ui.Run()
+		}(ui) // Loop will finish quickly, so pass ui in
+	}
+
+	g.Run() // Returns once every frontend has been closed
 }
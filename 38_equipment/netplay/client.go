@@ -0,0 +1,202 @@
+package netplay
+
+import (
+	"encoding/gob"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+)
+
+// Client stands in for a Game, so a UI can be handed its channels instead
+type Client struct {
+	InputChan chan *game.Input // Send inputs here, like Game.InputChan
+	LevelChan chan *game.Level // Receive levels from here, like Game.LevelChans
+
+	addr     string
+	closed   chan struct{}
+	closeOne sync.Once
+
+	mu      sync.Mutex // Guards everything below
+	conn    net.Conn
+	enc     *gob.Encoder
+	session string
+	seq     int
+	unacked []inputMsg  // Sent but not yet processed, resent after reconnecting
+	level   *game.Level // Latest level, to find items the UI points at
+}
+
+// Dial connects to a server and waits for the first level to arrive
+func Dial(addr string) (*Client, error) {
+	c := &Client{
+		InputChan: make(chan *game.Input),
+		LevelChan: make(chan *game.Level, 1),
+		addr:      addr,
+		closed:    make(chan struct{}),
+	}
+	dec, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	var state stateMsg
+	if err := dec.Decode(&state); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	c.receive(&state)
+
+	go c.read(dec)
+	go c.sendInputs()
+	return c, nil
+}
+
+// Channels lets a frontend play through the client, as if it were one of a game's players
+func (c *Client) Channels() game.Channels {
+	return game.NewChannels(c.InputChan, c.LevelChan)
+}
+
+// Close disconnects from the server, and closes LevelChan
+func (c *Client) Close() {
+	c.closeOne.Do(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		close(c.closed)
+		if c.conn != nil {
+			c.conn.Close()
+		}
+		close(c.LevelChan)
+	})
+}
+
+// connect does the handshake, and resends anything the server didn't get to
+func (c *Client) connect() (*gob.Decoder, error) {
+	conn, err := net.DialTimeout("tcp", c.addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	enc := gob.NewEncoder(conn)
+	dec := gob.NewDecoder(conn)
+
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if err := enc.Encode(hello{magic, Version, session}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var w welcome
+	if err := dec.Decode(&w); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if w.Error != "" {
+		conn.Close()
+		return nil, errors.New(w.Error)
+	}
+	if w.Version != Version {
+		conn.Close()
+		return nil, errVersion
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if session != "" && w.Session != session {
+		// The server forgot about us, so start counting again
+		c.seq = 0
+		c.unacked = nil
+	}
+	c.conn = conn
+	c.enc = enc
+	c.session = w.Session
+	c.ack(w.LastSeq)
+	for _, msg := range c.unacked {
+		if err := enc.Encode(msg); err != nil {
+			break // We'll find out when reading, and reconnect again
+		}
+	}
+	return dec, nil
+}
+
+// ack forgets about inputs the server has processed
+func (c *Client) ack(seq int) {
+	i := 0
+	for i < len(c.unacked) && c.unacked[i].Seq <= seq {
+		i++
+	}
+	c.unacked = c.unacked[i:]
+}
+
+// receive hands a level to the UI, replacing one it hasn't picked up yet
+func (c *Client) receive(state *stateMsg) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ack(state.Ack)
+	c.level = state.Level
+
+	select {
+	case <-c.closed:
+		return // LevelChan is closed
+	default:
+	}
+	select {
+	case <-c.LevelChan:
+	default:
+	}
+	c.LevelChan <- state.Level // Never blocks, we're the only sender and just made room
+}
+
+// read keeps decoding levels, reconnecting when the connection drops
+func (c *Client) read(dec *gob.Decoder) {
+	for {
+		var state stateMsg
+		err := dec.Decode(&state)
+		if err == nil {
+			c.receive(&state)
+			continue
+		}
+
+		// Keep trying with a growing delay until we're back, or closed
+		backoff := 100 * time.Millisecond
+		for {
+			select {
+			case <-c.closed:
+				return
+			case <-time.After(backoff):
+			}
+			dec, err = c.connect()
+			if err == nil {
+				break
+			}
+			if backoff < 5*time.Second {
+				backoff *= 2
+			}
+		}
+	}
+}
+
+// sendInputs numbers each input and swaps its item pointer for where the item is
+func (c *Client) sendInputs() {
+	for input := range c.InputChan {
+		switch input.Typ {
+		case game.QuitGame, game.CloseWindow:
+			c.Close()
+			continue // Keep draining, so the UI never blocks on a dead client
+		}
+
+		c.mu.Lock()
+		c.seq++
+		msg := inputMsg{Seq: c.seq, Typ: input.Typ, Choice: input.Choice, Pos: input.Pos}
+		if input.Item != nil {
+			msg.ItemWhere, msg.ItemIndex = findItem(c.level, input.Item)
+			msg.ItemName = input.Item.Name
+		}
+		c.unacked = append(c.unacked, msg)
+		if c.enc != nil {
+			// If this fails, the read loop will reconnect and resend it
+			c.enc.Encode(msg)
+		}
+		c.mu.Unlock()
+	}
+}
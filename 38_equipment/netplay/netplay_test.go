@@ -0,0 +1,354 @@
+package netplay
+
+import (
+	"encoding/gob"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+)
+
+// Everything here talks to a real server over loopback
+
+const timeout = 5 * time.Second
+
+// startServer runs a game with room for players clients
+func startServer(t *testing.T, players int) *Server {
+	t.Helper()
+	s, err := NewServer(game.NewGame(players), "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Run()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func dial(t *testing.T, s *Server) *Client {
+	t.Helper()
+	c, err := Dial(s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// nextLevel waits for the client to hand the UI a level
+func nextLevel(t *testing.T, c *Client) *game.Level {
+	t.Helper()
+	select {
+	case level, ok := <-c.LevelChan:
+		if !ok {
+			t.Fatal("LevelChan closed")
+		}
+		return level
+	case <-time.After(timeout):
+		t.Fatal("No level from the server")
+	}
+	return nil
+}
+
+// waitFor polls until cond is true
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for " + what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// acked is true once the server has processed every input the client sent
+func (c *Client) acked() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.unacked) == 0
+}
+
+func TestConnect(t *testing.T) {
+	s := startServer(t, 1)
+	c := dial(t, s)
+
+	level := nextLevel(t, c)
+	if level.Player == nil {
+		t.Fatal("First level has no player")
+	}
+	if level.Name == "" || len(level.Map) == 0 {
+		t.Fatal("First level has no map")
+	}
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == "" {
+		t.Fatal("No session from the handshake")
+	}
+}
+
+func TestInputIsAcked(t *testing.T) {
+	s := startServer(t, 1)
+	c := dial(t, s)
+	nextLevel(t, c)
+
+	c.InputChan <- &game.Input{Typ: game.Search}
+	nextLevel(t, c)
+	waitFor(t, "ack", c.acked)
+	if turns := s.game.Turn; turns != 1 {
+		t.Fatalf("Server played %d turns, want 1", turns)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	s := startServer(t, 1)
+	c := dial(t, s)
+	nextLevel(t, c)
+	c.mu.Lock()
+	session := c.session
+	c.conn.Close() // Pull the plug, the client should dial again by itself
+	c.mu.Unlock()
+
+	// Sent while disconnected, so it's resent after the handshake
+	c.InputChan <- &game.Input{Typ: game.Search}
+	waitFor(t, "resent input to be acked", c.acked)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != session {
+		t.Fatalf("Reconnected as session %s, want %s", c.session, session)
+	}
+	if c.seq != 1 {
+		t.Fatalf("Client seq is %d, want 1", c.seq)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sessions) != 1 {
+		t.Fatalf("Server has %d sessions, want 1", len(s.sessions))
+	}
+	if lastSeq := s.sessions[session].lastSeq; lastSeq != 1 {
+		t.Fatalf("Server lastSeq is %d, want 1", lastSeq)
+	}
+}
+
+// rawConn speaks the protocol by hand, to send inputs out of order and twice
+type rawConn struct {
+	conn net.Conn
+	enc  *gob.Encoder
+	dec  *gob.Decoder
+}
+
+func rawDial(t *testing.T, s *Server) *rawConn {
+	t.Helper()
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(timeout))
+	r := &rawConn{conn, gob.NewEncoder(conn), gob.NewDecoder(conn)}
+	if err := r.enc.Encode(hello{magic, Version, ""}); err != nil {
+		t.Fatal(err)
+	}
+	var w welcome
+	if err := r.dec.Decode(&w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Error != "" {
+		t.Fatal(w.Error)
+	}
+	return r
+}
+
+func (r *rawConn) send(t *testing.T, msg inputMsg) {
+	t.Helper()
+	if err := r.enc.Encode(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ack reads states until one acknowledges something new, and returns what it acknowledges.
+// Inputs that arrive out of order can get a state back before they're played.
+func (r *rawConn) ack(t *testing.T, last int) int {
+	t.Helper()
+	for {
+		var state stateMsg
+		if err := r.dec.Decode(&state); err != nil {
+			t.Fatal(err)
+		}
+		if state.Ack != last {
+			return state.Ack
+		}
+	}
+}
+
+func TestSeqAck(t *testing.T) {
+	s := startServer(t, 1)
+	r := rawDial(t, s)
+
+	// 2 waits for 1, then both are played
+	r.send(t, inputMsg{Seq: 2, Typ: game.Search})
+	r.send(t, inputMsg{Seq: 1, Typ: game.Search})
+	if ack := r.ack(t, 0); ack != 2 {
+		t.Fatalf("Acked %d, want 2", ack)
+	}
+
+	// Repeats are dropped without a reply
+	r.send(t, inputMsg{Seq: 1, Typ: game.Search})
+	r.send(t, inputMsg{Seq: 3, Typ: game.Search})
+	if ack := r.ack(t, 2); ack != 3 {
+		t.Fatalf("Acked %d, want 3", ack)
+	}
+	if turns := s.game.Turn; turns != 3 {
+		t.Fatalf("Server played %d turns, want 3", turns)
+	}
+}
+
+func TestBadVersion(t *testing.T) {
+	s := startServer(t, 1)
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := gob.NewEncoder(conn).Encode(hello{magic, Version + 1, ""}); err != nil {
+		t.Fatal(err)
+	}
+	var w welcome
+	if err := gob.NewDecoder(conn).Decode(&w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Error == "" {
+		t.Fatal("Server let a newer client in")
+	}
+}
+
+// A UI can ask to keep traveling after the route already ended. The server
+// still waits for a level after it, so the game has to send one.
+func TestKeepTravelingAfterRouteEnded(t *testing.T) {
+	s := startServer(t, 1)
+	r := rawDial(t, s)
+
+	r.send(t, inputMsg{Seq: 1, Typ: game.KeepTraveling})
+	if ack := r.ack(t, 0); ack != 1 {
+		t.Fatalf("Acked %d, want 1", ack)
+	}
+	r.send(t, inputMsg{Seq: 2, Typ: game.Search})
+	if ack := r.ack(t, 1); ack != 2 {
+		t.Fatalf("Acked %d, want 2", ack)
+	}
+}
+
+// Travel needs its target, and the route it plans has to reach the client so it keeps going
+func TestTravelOverNetwork(t *testing.T) {
+	s := startServer(t, 1)
+	c := dial(t, s)
+	level := nextLevel(t, c)
+
+	// Anywhere a few steps away the player has seen and can walk to
+	player := level.Player
+	var goal game.Pos
+	found := false
+	for y := player.Y - 3; y <= player.Y+3 && !found; y++ {
+		for x := player.X - 3; x <= player.X+3 && !found; x++ {
+			if y < 0 || y >= len(level.Map) || x < 0 || x >= len(level.Map[y]) {
+				continue
+			}
+			tile := level.Map[y][x]
+			if tile.Visible && tile.Rune == game.DirtFloor && tile.OverlayRune == game.Blank && abs(x-player.X)+abs(y-player.Y) >= 2 {
+				goal, found = game.Pos{X: x, Y: y}, true
+			}
+		}
+	}
+	if !found {
+		t.Skip("Nowhere to travel to from the start")
+	}
+
+	c.InputChan <- &game.Input{Typ: game.Travel, Pos: goal}
+	for i := 0; i < 20; i++ {
+		level = nextLevel(t, c)
+		if !level.Player.Traveling() {
+			break
+		}
+		c.InputChan <- &game.Input{Typ: game.KeepTraveling}
+	}
+	if level.Player.Pos == player.Pos {
+		t.Fatal("Travel didn't move the player")
+	}
+}
+
+// Each client plays its own hero, and only theirs moves when they send input
+func TestTwoHeroes(t *testing.T) {
+	s := startServer(t, 2)
+	clients := []*Client{dial(t, s), dial(t, s)}
+	level := nextLevel(t, clients[0])
+	starts := []*game.Player{level.Player, nextLevel(t, clients[1]).Player}
+	if starts[0].Name == starts[1].Name {
+		t.Fatalf("Both clients play %s", starts[0].Name)
+	}
+
+	// Step somewhere different, the heroes start next to each other
+	var taken []game.Pos
+	for i, c := range clients {
+		dir, to, ok := freeStep(level, starts[i].Pos, taken)
+		if !ok {
+			t.Skip("Nowhere for " + starts[i].Name + " to walk to")
+		}
+		taken = append(taken, to)
+		c.InputChan <- &game.Input{Typ: dir}
+		waitFor(t, "ack", c.acked)
+	}
+
+	for i, start := range starts {
+		player := s.game.Players[i]
+		if player.Name != start.Name {
+			t.Fatalf("Client %d started as %s, but the server has %s there", i+1, start.Name, player.Name)
+		}
+		if player.Pos != taken[i] {
+			t.Fatalf("%s is at %v, want %v", player.Name, player.Pos, taken[i])
+		}
+		if player.Stats.Turns != 1 {
+			t.Fatalf("%s played %d turns, want 1", player.Name, player.Stats.Turns)
+		}
+	}
+
+	// Every hero is taken, so nobody else gets in
+	if _, err := Dial(s.Addr()); err == nil || err.Error() != errFull.Error() {
+		t.Fatalf("Third client got %v, want %v", err, errFull)
+	}
+}
+
+// freeStep finds a way to step from pos onto empty floor, that isn't one of taken
+func freeStep(level *game.Level, pos game.Pos, taken []game.Pos) (game.InputType, game.Pos, bool) {
+	steps := []struct {
+		typ    game.InputType
+		dx, dy int
+	}{{game.Up, 0, -1}, {game.Down, 0, 1}, {game.Left, -1, 0}, {game.Right, 1, 0}}
+	for _, step := range steps {
+		to := game.Pos{X: pos.X + step.dx, Y: pos.Y + step.dy}
+		if to.Y < 0 || to.Y >= len(level.Map) || to.X < 0 || to.X >= len(level.Map[to.Y]) {
+			continue
+		}
+		tile := level.Map[to.Y][to.X]
+		free := tile.Rune == game.DirtFloor && tile.OverlayRune == game.Blank && level.Monsters[to] == nil && level.NPCs[to] == nil
+		for _, p := range level.Players {
+			free = free && p.Pos != to
+		}
+		for _, t := range taken {
+			free = free && t != to
+		}
+		if free {
+			return step.typ, to, true
+		}
+	}
+	return game.None, game.Pos{}, false
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
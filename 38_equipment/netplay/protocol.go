@@ -0,0 +1,137 @@
+// Package netplay runs a Game on a server, and lets UIs play it over TCP
+package netplay
+
+import (
+	"errors"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+)
+
+// Version goes up every time the wire format changes
+const Version = 2
+
+const magic = "GWG"
+
+// Where an input's item was when the client clicked on it
+const (
+	itemNone = iota
+	itemGround
+	itemInventory
+	itemShop
+)
+
+// hello is the first thing a client sends
+type hello struct {
+	Magic   string
+	Version int
+	Session string // Empty for a new session, or the one we were given to reconnect
+}
+
+// welcome is the server's reply to hello
+type welcome struct {
+	Version int
+	Session string
+	LastSeq int    // Last input processed for this session, so the client can resend the rest
+	Error   string // Non-empty if the handshake was refused
+}
+
+// inputMsg is an Input with the item pointer swapped for something the server can find
+type inputMsg struct {
+	Seq       int // Inputs are processed in order, and repeats are dropped
+	Typ       game.InputType
+	Choice    int
+	Pos       game.Pos // Where to travel to
+	ItemWhere int
+	ItemIndex int
+	ItemName  string // Double check the index still points at the same item
+}
+
+// stateMsg carries the level the UI should draw
+type stateMsg struct {
+	Ack   int // Last input processed for this session
+	Level *game.Level
+}
+
+var errVersion = errors.New("netplay: server and client versions don't match")
+
+var errFull = errors.New("netplay: every player on the server is taken")
+
+// wireLevel copies the parts of a level a UI needs, without the pointers back
+// to other levels or the dialogue graph, which gob can't send
+func wireLevel(level *game.Level) *game.Level {
+	wire := *level
+	wire.Portals = make(map[game.Pos]*game.LevelPos)
+	for pos, levelAndPos := range level.Portals {
+		wire.Portals[pos] = &game.LevelPos{Pos: levelAndPos.Pos} // Leave out the level itself
+	}
+
+	player := *level.Player
+	if player.Conversation != nil {
+		// Only send the choices the player can pick, so indexes match on both ends
+		node := &game.DialogueNode{Text: player.Conversation.Node.Text}
+		for _, choice := range player.AvailableChoices() {
+			node.Choices = append(node.Choices, &game.DialogueChoice{Description: choice.Description})
+		}
+		player.Conversation = &game.Conversation{NPC: player.Conversation.NPC, Node: node}
+	}
+	wire.Player = &player
+	return &wire
+}
+
+// findItem works out where an item pointer lives in the client's copy of the level
+func findItem(level *game.Level, item *game.Item) (int, int) {
+	for i, it := range level.Items[level.Player.Pos] {
+		if it == item {
+			return itemGround, i
+		}
+	}
+	for i, it := range level.Player.Items {
+		if it == item {
+			return itemInventory, i
+		}
+	}
+	if level.Player.Trading != nil {
+		for i, it := range level.Player.Trading.Shop.Stock {
+			if it == item {
+				return itemShop, i
+			}
+		}
+	}
+	return itemNone, 0
+}
+
+// resolveItem finds the server's item from where the client said it was
+func resolveItem(player *game.Player, where, index int, name string) *game.Item {
+	var items []*game.Item
+	switch where {
+	case itemGround:
+		items = player.CurrentLevel().Items[player.Pos]
+	case itemInventory:
+		items = player.Items
+	case itemShop:
+		if player.Trading != nil {
+			items = player.Trading.Shop.Stock
+		}
+	default:
+		return nil
+	}
+	if index < len(items) && items[index].Name == name {
+		return items[index]
+	}
+	// Things moved around while the input was in flight, settle for the first one with the same name
+	for _, item := range items {
+		if item.Name == name {
+			return item
+		}
+	}
+	return nil
+}
+
+// needsItem is true for inputs that are about a specific item
+func needsItem(typ game.InputType) bool {
+	switch typ {
+	case game.TakeItem, game.DropItem, game.EquipItem, game.Buy, game.Sell:
+		return true
+	}
+	return false
+}
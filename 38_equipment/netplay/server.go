@@ -0,0 +1,347 @@
+package netplay
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+)
+
+// Server owns a Game and feeds it inputs from every connected client
+type Server struct {
+	game       *game.Game
+	levelChans []chan *game.Level        // One for each player in the game, a session plays whichever one it is given
+	live       map[chan *game.Level]bool // Players still in the game, only used by the pump
+	listener   net.Listener
+	incoming   chan sessionInput
+	joins      chan *session
+	done       chan struct{}
+	closeOne   sync.Once
+
+	mu       sync.Mutex // Guards sessions and each session's peer
+	sessions map[string]*session
+}
+
+// session outlives connections, so a client can drop out and pick up where it left off
+type session struct {
+	id        string
+	levelChan chan *game.Level // Which player this session plays, and where the game sends them levels
+	lastSeq   int              // Only changed by the pump, while holding the lock
+	pending   map[int]inputMsg // Inputs that arrived ahead of one we're missing
+	peer      *peer            // nil while disconnected
+}
+
+// peer is a single connection for a session
+type peer struct {
+	conn net.Conn
+	enc  *gob.Encoder
+	out  chan *stateMsg // Holds at most the latest state, slow clients skip the rest
+	done chan struct{}
+}
+
+type sessionInput struct {
+	session *session
+	msg     inputMsg
+}
+
+// NewServer listens on addr, eg. ":7777", or "127.0.0.1:0" for a random port.
+// Each session plays one of the game's players, so make the game with one for
+// every client that can join. The server reads all of their level channels.
+func NewServer(g *game.Game, addr string) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		game:       g,
+		levelChans: append([]chan *game.Level{}, g.LevelChans...), // The game drops them as players leave
+		live:       make(map[chan *game.Level]bool),
+		listener:   listener,
+		incoming:   make(chan sessionInput),
+		joins:      make(chan *session),
+		done:       make(chan struct{}),
+		sessions:   make(map[string]*session),
+	}
+	return s, nil
+}
+
+// Addr is the address clients should dial
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Run plays the game until it ends or the server is closed
+func (s *Server) Run() {
+	go s.game.Run()
+	go s.accept()
+	s.pump()
+}
+
+// Close disconnects everyone and stops the server
+func (s *Server) Close() {
+	s.closeOne.Do(func() {
+		close(s.done)
+		s.listener.Close()
+		s.mu.Lock()
+		for _, sess := range s.sessions {
+			if sess.peer != nil {
+				sess.peer.conn.Close()
+			}
+		}
+		s.mu.Unlock()
+	})
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return // Listener was closed
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle does the handshake, then reads inputs until the connection drops
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	enc := gob.NewEncoder(conn)
+	dec := gob.NewDecoder(conn)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var h hello
+	if err := dec.Decode(&h); err != nil {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	if h.Magic != magic || h.Version != Version {
+		enc.Encode(welcome{Version: Version, Error: errVersion.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	sess := s.sessions[h.Session]
+	if sess == nil {
+		levelChan := s.freePlayer()
+		if levelChan == nil {
+			s.mu.Unlock()
+			enc.Encode(welcome{Version: Version, Error: errFull.Error()})
+			return
+		}
+		sess = &session{id: newSessionID(), levelChan: levelChan, pending: make(map[int]inputMsg)}
+		s.sessions[sess.id] = sess
+	}
+	if sess.peer != nil {
+		sess.peer.conn.Close() // A reconnect replaces the old connection
+	}
+	p := &peer{conn, enc, make(chan *stateMsg, 1), make(chan struct{})}
+	sess.peer = p
+	lastSeq := sess.lastSeq
+	s.mu.Unlock()
+
+	// Tell the client where to resend from
+	if err := enc.Encode(welcome{Version: Version, Session: sess.id, LastSeq: lastSeq}); err != nil {
+		return
+	}
+	go p.write()
+	defer close(p.done)
+
+	select {
+	case s.joins <- sess:
+	case <-s.done:
+		return
+	}
+
+	for {
+		var msg inputMsg
+		if err := dec.Decode(&msg); err != nil {
+			break
+		}
+		select {
+		case s.incoming <- sessionInput{sess, msg}:
+		case <-s.done:
+			return
+		}
+	}
+
+	s.mu.Lock()
+	if sess.peer == p {
+		sess.peer = nil
+	}
+	s.mu.Unlock()
+}
+
+// freePlayer picks a player no session is playing yet, or nil if the server is full.
+// Call with the lock held.
+func (s *Server) freePlayer() chan *game.Level {
+	taken := make(map[chan *game.Level]bool)
+	for _, sess := range s.sessions {
+		taken[sess.levelChan] = true
+	}
+	for _, levelChan := range s.levelChans {
+		if !taken[levelChan] {
+			return levelChan
+		}
+	}
+	return nil
+}
+
+// pump is the only goroutine that talks to the game, so the level is never
+// read while the game is changing it
+func (s *Server) pump() {
+	for _, levelChan := range s.levelChans {
+		s.live[levelChan] = true
+	}
+	latest := make(map[chan *game.Level]*game.Level) // What each player last saw
+	if !s.nextLevels(latest) {
+		s.Close()
+		return
+	}
+
+	for {
+		select {
+		case sess := <-s.joins:
+			s.mu.Lock()
+			if sess.peer != nil {
+				sess.peer.send(&stateMsg{sess.lastSeq, latest[sess.levelChan]})
+			}
+			s.mu.Unlock()
+		case in := <-s.incoming:
+			sess := in.session
+			if in.msg.Seq <= sess.lastSeq {
+				continue // Already processed, the client resent it after reconnecting
+			}
+			sess.pending[in.msg.Seq] = in.msg
+			for {
+				msg, exists := sess.pending[sess.lastSeq+1]
+				if !exists {
+					break // Wait for the missing input
+				}
+				delete(sess.pending, msg.Seq)
+				s.mu.Lock()
+				sess.lastSeq = msg.Seq
+				s.mu.Unlock()
+				input := s.toInput(sess, msg)
+				if input == nil {
+					continue
+				}
+				s.game.InputChan <- input
+				if !s.nextLevels(latest) {
+					s.Close() // Game over for everyone
+					return
+				}
+			}
+			s.broadcast(latest)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// nextLevels waits for the level the game sends every player after each input.
+// Reading them all every time means none are left over to be mistaken for the next one.
+// Returns false once every player has left the game.
+func (s *Server) nextLevels(latest map[chan *game.Level]*game.Level) bool {
+	for _, levelChan := range s.levelChans {
+		if !s.live[levelChan] {
+			continue
+		}
+		level, ok := <-levelChan
+		if !ok {
+			delete(s.live, levelChan) // Their run ended, they keep seeing how it did
+			continue
+		}
+		latest[levelChan] = snapshot(level)
+	}
+	return len(s.live) > 0
+}
+
+// player finds the hero a session plays. The game moves everyone down when someone leaves,
+// so look for its level channel rather than remembering an index.
+func (s *Server) player(sess *session) *game.Player {
+	for i, levelChan := range s.game.LevelChans {
+		if levelChan == sess.levelChan {
+			return s.game.Players[i]
+		}
+	}
+	return nil
+}
+
+// toInput turns a message back into an Input from the session's player, or nil if it can't be played
+func (s *Server) toInput(sess *session, msg inputMsg) *game.Input {
+	switch msg.Typ {
+	case game.None, game.QuitGame, game.CloseWindow:
+		return nil // Clients can only leave, not end the game for everyone
+	}
+	if !s.live[sess.levelChan] {
+		return nil // Their hero is gone
+	}
+	input := &game.Input{Typ: msg.Typ, Choice: msg.Choice, Pos: msg.Pos, LevelChannel: sess.levelChan}
+	if needsItem(msg.Typ) {
+		input.Item = resolveItem(s.player(sess), msg.ItemWhere, msg.ItemIndex, msg.ItemName)
+		if input.Item == nil {
+			return nil // Someone else got to it first
+		}
+	}
+	return input
+}
+
+// broadcast sends every session what its own player sees
+func (s *Server) broadcast(latest map[chan *game.Level]*game.Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sess := range s.sessions {
+		if sess.peer != nil {
+			sess.peer.send(&stateMsg{sess.lastSeq, latest[sess.levelChan]})
+		}
+	}
+}
+
+// send replaces whatever state hasn't been written yet with this one
+func (p *peer) send(msg *stateMsg) {
+	select {
+	case <-p.out:
+	default:
+	}
+	p.out <- msg
+}
+
+func (p *peer) write() {
+	for {
+		select {
+		case msg := <-p.out:
+			if err := p.enc.Encode(msg); err != nil {
+				p.conn.Close()
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// snapshot deep copies the level, so writers can encode it while the game moves on
+func snapshot(level *game.Level) *game.Level {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(wireLevel(level)); err != nil {
+		panic(err)
+	}
+	var snap game.Level
+	if err := gob.NewDecoder(&buf).Decode(&snap); err != nil {
+		panic(err)
+	}
+	return &snap
+}
+
+func newSessionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,28 @@
+package main
+
+// Runs the game without a window, for remote UIs to connect to with -connect
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/maxproske/games-with-go/38_equipment/assets"
+	"github.com/maxproske/games-with-go/38_equipment/game"
+	"github.com/maxproske/games-with-go/38_equipment/netplay"
+)
+
+func main() {
+	addr := flag.String("addr", ":7777", "address to listen on")
+	players := flag.Int("players", 2, "how many clients can play at once, each with their own hero")
+	flag.StringVar(&assets.Dir, "assets", "", "directory laid out like 38_equipment to load maps from instead of the built in ones")
+	flag.Parse()
+
+	// Make new game, with a level channel for each client's hero
+	game := game.NewGame(*players)
+	server, err := netplay.NewServer(game, *addr)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Listening on", server.Addr())
+	server.Run()
+}
@@ -0,0 +1,210 @@
+package ui2d
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Animations are worked out when a new level arrives, by comparing it with the last one,
+// then drawn from the clock every frame. Nothing waits on them, so input carries on as normal.
+
+// How long each animation lasts in milliseconds
+const (
+	slideTime = 80 // Shorter than travelDelay, so traveling doesn't fall behind
+	lungeTime = 150
+	flashTime = 150
+	floatTime = 800
+)
+
+type animKind int
+
+const (
+	slide animKind = iota // Walk from the last tile to this one
+	lunge                 // Jump towards dir and back
+	flash                 // Tint red after being hit
+)
+
+// anim moves or tints whatever is drawn on a tile
+type anim struct {
+	kind  animKind
+	pos   game.Pos // Tile the entity is on now
+	dir   game.Pos // Where it came from for slides, who it attacked for lunges
+	start uint32
+}
+
+// floater is text that drifts up from a tile and fades, eg. damage
+type floater struct {
+	text  string
+	pos   game.Pos
+	color sdl.Color
+	start uint32
+}
+
+func (a *anim) length() uint32 {
+	switch a.kind {
+	case slide:
+		return slideTime
+	case lunge:
+		return lungeTime
+	}
+	return flashTime
+}
+
+// animate starts animations for everything that happened between two levels
+func (ui *ui) animate(prev, level *game.Level) {
+	if prev == nil || prev.Name != level.Name {
+		// New level, nothing to animate from
+		ui.anims = nil
+		ui.floaters = nil
+		return
+	}
+	now := sdl.GetTicks()
+	moved := func(from, to game.Pos) {
+		ui.dropAnims(from)
+		ui.anims = append(ui.anims, &anim{slide, to, game.Pos{from.X - to.X, from.Y - to.Y}, now})
+	}
+
+	update := game.Diff(prev, level)
+	for _, move := range update.Moves {
+		moved(move.From, move.To)
+	}
+	if prev.Player.Pos != level.Player.Pos && adjacent(prev.Player.Pos, level.Player.Pos) {
+		moved(prev.Player.Pos, level.Player.Pos)
+	}
+	for _, player := range level.Players {
+		for _, before := range prev.Players {
+			if player != level.Player && before.Name == player.Name && before.Pos != player.Pos && adjacent(before.Pos, player.Pos) {
+				moved(before.Pos, player.Pos)
+			}
+		}
+	}
+
+	last := 0
+	if n := len(prev.Combats); n > 0 {
+		last = prev.Combats[n-1].Seq
+	}
+	for _, combat := range level.Combats {
+		if combat.Seq > last {
+			ui.animateCombat(combat, now)
+		}
+	}
+}
+
+// animateCombat lunges the attacker at its victim, and shows what it did
+func (ui *ui) animateCombat(combat game.Combat, now uint32) {
+	a, v := combat.Attacker, combat.Victim
+	ui.anims = append(ui.anims, &anim{lunge, a, game.Pos{v.X - a.X, v.Y - a.Y}, now})
+	if !combat.Killed {
+		ui.anims = append(ui.anims, &anim{flash, v, game.Pos{}, now})
+		ui.floaters = append(ui.floaters, &floater{"-" + strconv.Itoa(combat.Damage), v, sdl.Color{255, 60, 60, 0}, now})
+	} else {
+		ui.floaters = append(ui.floaters, &floater{"Killed", v, sdl.Color{255, 255, 255, 0}, now})
+	}
+}
+
+func adjacent(a, b game.Pos) bool {
+	dx := a.X - b.X
+	dy := a.Y - b.Y
+	return dx*dx+dy*dy == 1
+}
+
+// dropAnims forgets animations on a tile, eg. because whoever was there moved on
+func (ui *ui) dropAnims(pos game.Pos) {
+	kept := ui.anims[:0]
+	for _, a := range ui.anims {
+		if a.pos != pos {
+			kept = append(kept, a)
+		}
+	}
+	ui.anims = kept
+}
+
+// expireAnims drops animations and floaters that have finished
+func (ui *ui) expireAnims() {
+	now := sdl.GetTicks()
+	kept := ui.anims[:0]
+	for _, a := range ui.anims {
+		if now-a.start < a.length() {
+			kept = append(kept, a)
+		}
+	}
+	ui.anims = kept
+	keptFloaters := ui.floaters[:0]
+	for _, f := range ui.floaters {
+		if now-f.start < floatTime {
+			keptFloaters = append(keptFloaters, f)
+		}
+	}
+	ui.floaters = keptFloaters
+}
+
+// entityRect is where to draw whatever is on a tile this frame, and whether it was just hit
+func (ui *ui) entityRect(pos game.Pos) (*sdl.Rect, bool) {
+	now := sdl.GetTicks()
+	ts := float64(ui.tileSize)
+	x := float64(pos.X)*ts + float64(ui.offsetX)
+	y := float64(pos.Y)*ts + float64(ui.offsetY)
+	hit := false
+	for _, a := range ui.anims {
+		if a.pos != pos {
+			continue
+		}
+		t := float64(now-a.start) / float64(a.length())
+		if t >= 1 {
+			continue
+		}
+		switch a.kind {
+		case slide:
+			x += float64(a.dir.X) * ts * (1 - t)
+			y += float64(a.dir.Y) * ts * (1 - t)
+		case lunge:
+			out := math.Sin(math.Pi*t) * ts * 0.3 // Out a third of a tile and back
+			x += float64(a.dir.X) * out
+			y += float64(a.dir.Y) * out
+		case flash:
+			hit = true
+		}
+	}
+	return &sdl.Rect{int32(x), int32(y), int32(ts), int32(ts)}, hit
+}
+
+// drawEntity draws a sprite with its animations, lit by the tile it's on
+func (ui *ui) drawEntity(s *sprite, pos game.Pos) {
+	dstRect, hit := ui.entityRect(pos)
+	if hit {
+		ui.textureAtlas.SetColorMod(255, 80, 80)
+	} else {
+		ui.tint(pos)
+	}
+	ui.drawSprite(s, dstRect)
+}
+
+// drawHealthBar goes over monsters that have been hurt
+func (ui *ui) drawHealthBar(monster *game.Monster) {
+	if monster.Hitpoints >= monster.MaxHitpoints || monster.MaxHitpoints <= 0 {
+		return
+	}
+	rect, _ := ui.entityRect(monster.Pos)
+	width := rect.W * int32(monster.Hitpoints) / int32(monster.MaxHitpoints)
+	ui.renderer.Copy(ui.slotBackground, nil, &sdl.Rect{rect.X, rect.Y - 5, rect.W, 4})
+	ui.renderer.Copy(ui.healthBar, nil, &sdl.Rect{rect.X, rect.Y - 5, width, 4})
+}
+
+// drawFloaters draws damage numbers drifting up from where they happened
+func (ui *ui) drawFloaters() {
+	now := sdl.GetTicks()
+	for _, f := range ui.floaters {
+		t := float64(now-f.start) / floatTime
+		tex := ui.stringToTexture(f.text, f.color, FontSmall)
+		_, _, w, h, _ := tex.Query()
+		ts := int32(ui.tileSize)
+		x := int32(f.pos.X)*ts + ui.offsetX + ts/2 - w/2
+		y := int32(f.pos.Y)*ts + ui.offsetY - int32(t*float64(ts)*0.75)
+		tex.SetAlphaMod(uint8(255 * (1 - t)))
+		ui.renderer.Copy(tex, nil, &sdl.Rect{x, y, w, h})
+		tex.SetAlphaMod(255) // The texture is cached, so put it back
+	}
+}
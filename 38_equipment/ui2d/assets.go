@@ -0,0 +1,76 @@
+package ui2d
+
+import (
+	"fmt"
+
+	"github.com/maxproske/games-with-go/38_equipment/assets"
+	"github.com/veandco/go-sdl2/mix"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Files ui2d loads, built in or from -assets, see the assets package
+const (
+	tilesFile = "assets/ui/tiles.png"
+	atlasFile = "assets/ui/atlas.txt"
+	fontFile  = "assets/ui/gothic.ttf"
+	soundDir  = "assets/ui/sounds/"
+)
+
+// rwops lets SDL load a file from memory. Music and fonts keep reading it as they
+// play, so hold on to data for as long as they're open.
+func rwops(data []byte) *sdl.RWops {
+	rw, err := sdl.RWFromMem(data)
+	if err != nil {
+		panic(err)
+	}
+	return rw
+}
+
+// loadSound reads a whole sound into memory, eg. "footstep00.ogg"
+func loadSound(name string) *mix.Chunk {
+	chunk, err := mix.LoadWAVRW(rwops(assets.UI.MustRead(soundDir+name)), true)
+	if err != nil {
+		panic(err)
+	}
+	return chunk
+}
+
+// loadMusic streams music from a file, which has to stay in ui.musicData while it plays
+func (ui *ui) loadMusic(name string) *mix.Music {
+	ui.musicData = assets.UI.MustRead(soundDir + name)
+	mus, err := mix.LoadMUSRW(rwops(ui.musicData), 1)
+	if err != nil {
+		panic(err)
+	}
+	return mus
+}
+
+// reloadAssets picks up changes to the atlas and its index, when playing with -assets
+func (ui *ui) reloadAssets() {
+	for {
+		select {
+		case name := <-ui.assetChanges:
+			ui.reloadAsset(name)
+		default:
+			return
+		}
+	}
+}
+
+func (ui *ui) reloadAsset(name string) {
+	defer func() {
+		// Editors save half written files, keep drawing the old sprites until it's fixed
+		if r := recover(); r != nil {
+			fmt.Println("Couldn't reload", name+":", r)
+		}
+	}()
+	if name == tilesFile {
+		tex := ui.imgFileToTexture(tilesFile)
+		ui.textureAtlas.Destroy()
+		ui.textureAtlas = tex
+	}
+	// Cells in the index wrap at the edge of the image, so read it again either way
+	ui.loadAtlas()
+	ui.tileCache = tileCache{}
+	fmt.Println("Reloaded", name)
+}
@@ -0,0 +1,174 @@
+package ui2d
+
+import (
+	"sort"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Autotiles pick a picture from which neighbours are the same kind of tile, so walls connect.
+// In atlas.txt, a sprite with auto4 has 16 pictures picked by the 4 neighbours:
+// bit 1 is north, 2 east, 4 south and 8 west, so picture 0 stands alone and 15 is surrounded.
+// auto8 is the 47 picture blob set picked by all 8 neighbours, in order of blobMasks below.
+// Corners only count when both edges next to them connect too.
+
+// Neighbour bits for auto8, clockwise from north
+const (
+	bitN = 1 << iota
+	bitNE
+	bitE
+	bitSE
+	bitS
+	bitSW
+	bitW
+	bitNW
+)
+
+// autotile is a set of sprites for one rune
+type autotile struct {
+	neighbours int // 4 or 8
+	rects      []sdl.Rect
+}
+
+// blobIndex maps an 8 neighbour mask, after dropping corners that don't count, to a sprite in a 47 sprite set
+var blobIndex = make(map[int]int)
+
+// blobMasks are the 47 masks that are left after dropping corners, smallest first
+var blobMasks []int
+
+func init() {
+	seen := make(map[int]bool)
+	for mask := 0; mask < 256; mask++ {
+		reduced := reduceMask(mask)
+		if !seen[reduced] {
+			seen[reduced] = true
+			blobMasks = append(blobMasks, reduced)
+		}
+	}
+	sort.Ints(blobMasks)
+	for i, mask := range blobMasks {
+		blobIndex[mask] = i
+	}
+}
+
+// reduceMask drops corners that don't have both of their edges, since they look the same either way
+func reduceMask(mask int) int {
+	corners := []struct{ corner, a, b int }{
+		{bitNE, bitN, bitE},
+		{bitSE, bitS, bitE},
+		{bitSW, bitS, bitW},
+		{bitNW, bitN, bitW},
+	}
+	for _, c := range corners {
+		if mask&c.a == 0 || mask&c.b == 0 {
+			mask &^= c.corner
+		}
+	}
+	return mask
+}
+
+// connects is true if a neighbour joins up with a tile, off the map counts so walls run into the edge
+func connects(level *game.Level, pos game.Pos, r rune) bool {
+	if pos.Y < 0 || pos.Y >= len(level.Map) || pos.X < 0 || pos.X >= len(level.Map[pos.Y]) {
+		return true
+	}
+	return level.Map[pos.Y][pos.X].Rune == r
+}
+
+// pick chooses the sprite for the tile at pos from its neighbours
+func (a *autotile) pick(level *game.Level, pos game.Pos) sdl.Rect {
+	r := level.Map[pos.Y][pos.X].Rune
+	offsets := []struct {
+		bit  int
+		x, y int
+	}{
+		{bitN, 0, -1}, {bitNE, 1, -1}, {bitE, 1, 0}, {bitSE, 1, 1},
+		{bitS, 0, 1}, {bitSW, -1, 1}, {bitW, -1, 0}, {bitNW, -1, -1},
+	}
+	mask := 0
+	for _, o := range offsets {
+		if connects(level, game.Pos{pos.X + o.x, pos.Y + o.y}, r) {
+			mask |= o.bit
+		}
+	}
+	if a.neighbours == 8 {
+		return a.rects[blobIndex[reduceMask(mask)]]
+	}
+	// Squash the edges down to bits 1, 2, 4 and 8
+	index := 0
+	for i, bit := range []int{bitN, bitE, bitS, bitW} {
+		if mask&bit != 0 {
+			index |= 1 << uint(i)
+		}
+	}
+	return a.rects[index]
+}
+
+// tileCache remembers which picture every tile of a level uses, so we only choose again when tiles change
+type tileCache struct {
+	name    string
+	runes   [][]rune
+	rects   [][]sdl.Rect
+	sprites [][]*sprite // Animated tiles, which pick their picture every frame instead
+}
+
+// rect is the picture to draw for a tile now
+func (tc *tileCache) rect(x, y int, now uint32) sdl.Rect {
+	if s := tc.sprites[y][x]; s != nil {
+		return s.frame(now)
+	}
+	return tc.rects[y][x]
+}
+
+// tiles returns the pictures for every tile, rebuilding the cache for a new level or changed tiles
+func (ui *ui) tiles(level *game.Level) *tileCache {
+	tc := &ui.tileCache
+	if tc.name == level.Name && !runesChanged(tc.runes, level) {
+		return tc
+	}
+	tc.name = level.Name
+	tc.runes = make([][]rune, len(level.Map))
+	tc.rects = make([][]sdl.Rect, len(level.Map))
+	tc.sprites = make([][]*sprite, len(level.Map))
+
+	// Set reproducable seed, so variations stay put when we rebuild
+	ui.r.Seed(1)
+	for y, row := range level.Map {
+		tc.runes[y] = make([]rune, len(row))
+		tc.rects[y] = make([]sdl.Rect, len(row))
+		tc.sprites[y] = make([]*sprite, len(row))
+		for x, tile := range row {
+			tc.runes[y][x] = tile.Rune
+			if tile.Rune == game.Blank {
+				continue
+			}
+			s := ui.tileSprite(tile.Rune)
+			switch {
+			case s.auto != nil:
+				tc.rects[y][x] = s.auto.pick(level, game.Pos{x, y})
+			case s.frameTime > 0:
+				tc.sprites[y][x] = s
+			default:
+				tc.rects[y][x] = s.rects[ui.r.Intn(len(s.rects))] // Random number between 1 and length of variations
+			}
+		}
+	}
+	return tc
+}
+func runesChanged(runes [][]rune, level *game.Level) bool {
+	if len(runes) != len(level.Map) {
+		return true
+	}
+	for y, row := range level.Map {
+		if len(runes[y]) != len(row) {
+			return true
+		}
+		for x, tile := range row {
+			if runes[y][x] != tile.Rune {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,325 @@
+package ui2d
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed" // For the default bindings
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Bindings are saved here, in the -assets directory if there is one, or else the working directory
+const bindingsFile = "ui2d/bindings.txt"
+
+//go:embed bindings.txt
+var defaultBindings []byte // Used until some are saved
+
+// bindingsHeader explains the file, it's written back out whenever the bindings are saved
+const bindingsHeader = `# What each action is bound to, changed in the game with F1
+# key NAME uses SDL's key names, eg. Up, Escape, F5, T, .
+# button NAME is a controller button, eg. A, DPAD_UP, START
+# axis NAME + or - is a controller stick or trigger pushed that way, eg. LEFTY -
+`
+
+// How far a stick has to be pushed to count as pressed, out of 32767
+// 10_pong3 used 1500 for smooth movement, but we want a deliberate push
+const axisDeadZone = 16000
+
+// Held movement repeats after keyRepeatDelay, then every keyRepeatRate milliseconds
+const (
+	keyRepeatDelay = 250
+	keyRepeatRate  = 100
+)
+
+// actions in the order the rebinding screen lists them
+var actions = []string{"Up", "Down", "Left", "Right", "TakeAll", "Search", "Descend", "Ascend", "Talk", "LeaveShop",
+	"TravelStairs", "Explore", "SaveGame", "LoadGame", "Inventory", "Journal", "Map", "Fullscreen", "ZoomIn", "ZoomOut", "Bindings"}
+
+// actionInputs are the actions that go to the game, the rest only change the UI
+var actionInputs = map[string]game.InputType{
+	"Up":           game.Up,
+	"Down":         game.Down,
+	"Left":         game.Left,
+	"Right":        game.Right,
+	"TakeAll":      game.TakeAll,
+	"Search":       game.Search,
+	"Descend":      game.Descend,
+	"Ascend":       game.Ascend,
+	"Talk":         game.Talk,
+	"LeaveShop":    game.LeaveShop,
+	"TravelStairs": game.TravelStairs,
+	"Explore":      game.Explore,
+	"SaveGame":     game.SaveGame,
+	"LoadGame":     game.LoadGame,
+}
+
+// repeats are the actions that keep happening while held
+var repeats = map[string]bool{"Up": true, "Down": true, "Left": true, "Right": true}
+
+var buttonNames = map[string]sdl.GameControllerButton{
+	"A":             sdl.CONTROLLER_BUTTON_A,
+	"B":             sdl.CONTROLLER_BUTTON_B,
+	"X":             sdl.CONTROLLER_BUTTON_X,
+	"Y":             sdl.CONTROLLER_BUTTON_Y,
+	"BACK":          sdl.CONTROLLER_BUTTON_BACK,
+	"GUIDE":         sdl.CONTROLLER_BUTTON_GUIDE,
+	"START":         sdl.CONTROLLER_BUTTON_START,
+	"LEFTSTICK":     sdl.CONTROLLER_BUTTON_LEFTSTICK,
+	"RIGHTSTICK":    sdl.CONTROLLER_BUTTON_RIGHTSTICK,
+	"LEFTSHOULDER":  sdl.CONTROLLER_BUTTON_LEFTSHOULDER,
+	"RIGHTSHOULDER": sdl.CONTROLLER_BUTTON_RIGHTSHOULDER,
+	"DPAD_UP":       sdl.CONTROLLER_BUTTON_DPAD_UP,
+	"DPAD_DOWN":     sdl.CONTROLLER_BUTTON_DPAD_DOWN,
+	"DPAD_LEFT":     sdl.CONTROLLER_BUTTON_DPAD_LEFT,
+	"DPAD_RIGHT":    sdl.CONTROLLER_BUTTON_DPAD_RIGHT,
+}
+
+var axisNames = map[string]sdl.GameControllerAxis{
+	"LEFTX":        sdl.CONTROLLER_AXIS_LEFTX,
+	"LEFTY":        sdl.CONTROLLER_AXIS_LEFTY,
+	"RIGHTX":       sdl.CONTROLLER_AXIS_RIGHTX,
+	"RIGHTY":       sdl.CONTROLLER_AXIS_RIGHTY,
+	"TRIGGERLEFT":  sdl.CONTROLLER_AXIS_TRIGGERLEFT,
+	"TRIGGERRIGHT": sdl.CONTROLLER_AXIS_TRIGGERRIGHT,
+}
+
+type bindingKind int
+
+const (
+	keyBinding bindingKind = iota
+	buttonBinding
+	axisBinding
+)
+
+// binding is one key, button or push of a stick
+type binding struct {
+	kind bindingKind
+	code int // Scancode, button or axis
+	sign int // Which way an axis is pushed, 1 or -1
+}
+
+// String is how a binding is written in the bindings file
+func (b binding) String() string {
+	switch b.kind {
+	case buttonBinding:
+		for name, button := range buttonNames {
+			if int(button) == b.code {
+				return "button " + name
+			}
+		}
+	case axisBinding:
+		for name, axis := range axisNames {
+			if int(axis) == b.code {
+				if b.sign < 0 {
+					return "axis " + name + " -"
+				}
+				return "axis " + name + " +"
+			}
+		}
+	}
+	return "key " + sdl.GetScancodeName(sdl.Scancode(b.code))
+}
+
+// parseBinding reads a line of the bindings file, eg. "axis LEFTY -"
+func parseBinding(line string) binding {
+	words := strings.Fields(line)
+	switch {
+	case words[0] == "key" && len(words) == 2:
+		code := sdl.GetScancodeFromName(words[1])
+		if code == sdl.SCANCODE_UNKNOWN {
+			panic("Unknown key in bindings: " + line)
+		}
+		return binding{keyBinding, int(code), 0}
+	case words[0] == "button" && len(words) == 2:
+		button, ok := buttonNames[words[1]]
+		if !ok {
+			panic("Unknown controller button in bindings: " + line)
+		}
+		return binding{buttonBinding, int(button), 0}
+	case words[0] == "axis" && len(words) == 3 && (words[2] == "+" || words[2] == "-"):
+		axis, ok := axisNames[words[1]]
+		if !ok {
+			panic("Unknown controller axis in bindings: " + line)
+		}
+		sign := 1
+		if words[2] == "-" {
+			sign = -1
+		}
+		return binding{axisBinding, int(axis), sign}
+	}
+	panic("Invalid bindings line: " + line)
+}
+
+// bindings maps action names to everything that does them
+type bindings map[string][]binding
+
+// conflict returns the action already using a binding, or "" if it's free
+func (b bindings) conflict(bind binding) string {
+	for action, binds := range b {
+		for _, other := range binds {
+			if other == bind {
+				return action
+			}
+		}
+	}
+	return ""
+}
+
+func loadBindings(filename string) bindings {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		data = defaultBindings
+	} else if err != nil {
+		panic(err)
+	}
+
+	known := make(map[string]bool)
+	for _, action := range actions {
+		known[action] = true
+	}
+	b := make(bindings)
+	current := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '@' {
+			current = strings.TrimSpace(line[1:])
+			if !known[current] {
+				panic("Unknown action in bindings: " + current)
+			}
+			continue
+		}
+		if current == "" {
+			panic("Binding before first action: " + line)
+		}
+		bind := parseBinding(line)
+		if other := b.conflict(bind); other != "" {
+			panic(bind.String() + " is bound to both " + other + " and " + current)
+		}
+		b[current] = append(b[current], bind)
+	}
+	if err := scanner.Err(); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// save writes the bindings back out, after they were changed on the rebinding screen
+func (b bindings) save(filename string) {
+	var sb strings.Builder
+	sb.WriteString(bindingsHeader)
+	for _, action := range actions {
+		sb.WriteString("\n@" + action + "\n")
+		for _, bind := range b[action] {
+			sb.WriteString(bind.String() + "\n")
+		}
+	}
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		panic(err)
+	}
+	err = os.WriteFile(filename, []byte(sb.String()), 0644)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// controllerEvent opens controllers as they are plugged in, and closes them when they are pulled out
+// SDL sends an added event for every controller that was already plugged in when it started
+func (ui *ui) controllerEvent(e *sdl.ControllerDeviceEvent) {
+	switch e.Type {
+	case sdl.CONTROLLERDEVICEADDED:
+		// Which is the device index when added
+		if !sdl.IsGameController(int(e.Which)) {
+			return
+		}
+		controller := sdl.GameControllerOpen(int(e.Which))
+		if controller == nil {
+			return
+		}
+		ui.controllers[controller.Joystick().InstanceID()] = controller
+	case sdl.CONTROLLERDEVICEREMOVED:
+		// And the instance id when removed
+		if controller, ok := ui.controllers[e.Which]; ok {
+			controller.Close()
+			delete(ui.controllers, e.Which)
+		}
+	}
+}
+
+// updateActions works out which actions happen this frame, from keys and every controller
+// Keys only count in the focused window, since every window sees the same keyboard
+func (ui *ui) updateActions(focused bool) {
+	now := sdl.GetTicks()
+	ui.prevPad = ui.pad
+	ui.pad = make(map[binding]bool)
+	for _, controller := range ui.controllers {
+		for _, button := range buttonNames {
+			if controller.Button(button) == 1 {
+				ui.pad[binding{buttonBinding, int(button), 0}] = true
+			}
+		}
+		for _, axis := range axisNames {
+			value := int(controller.Axis(axis))
+			if value > axisDeadZone {
+				ui.pad[binding{axisBinding, int(axis), 1}] = true
+			} else if -value > axisDeadZone {
+				ui.pad[binding{axisBinding, int(axis), -1}] = true
+			}
+		}
+	}
+
+	ui.prevHeld = ui.held
+	ui.held = make(map[string]bool)
+	ui.fired = make(map[string]bool)
+	for action, binds := range ui.bindings {
+		for _, b := range binds {
+			if (b.kind == keyBinding && focused && ui.keyboardState[b.code] != 0) || (b.kind != keyBinding && ui.pad[b]) {
+				ui.held[action] = true
+				break
+			}
+		}
+		if !ui.held[action] {
+			continue
+		}
+		if !ui.prevHeld[action] {
+			ui.fired[action] = true
+			ui.repeatAt[action] = now + keyRepeatDelay
+		} else if repeats[action] && now >= ui.repeatAt[action] {
+			ui.fired[action] = true
+			ui.repeatAt[action] = now + keyRepeatRate
+		}
+	}
+}
+
+// actionPressed is true on the frame an action was pressed, and while held for actions that repeat
+func (ui *ui) actionPressed(action string) bool {
+	return ui.fired[action]
+}
+
+// padPressed is true on the frame a controller button or axis was pushed
+func (ui *ui) padPressed(b binding) bool {
+	return ui.pad[b] && !ui.prevPad[b]
+}
+
+// pressedInput returns the game input for the first action pressed this frame, if any
+func (ui *ui) pressedInput(level *game.Level) game.InputType {
+	for _, action := range actions {
+		typ, ok := actionInputs[action]
+		if !ok || !ui.actionPressed(action) {
+			continue
+		}
+		if typ == game.LeaveShop && level.Player.Trading == nil {
+			continue // Escape does nothing outside the shop
+		}
+		return typ
+	}
+	return game.None
+}
@@ -0,0 +1,58 @@
+package ui2d
+
+import (
+	"strconv"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func (ui *ui) getDialogueRect(lines int) *sdl.Rect {
+	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A")
+	padding := int32(fontSizeY / 2)
+	dialogueWidth := int32(float32(ui.winWidth) * 0.6)
+	dialogueHeight := int32(lines*fontSizeY) + padding*2
+	offsetX := (int32(ui.winWidth) - dialogueWidth) / 2
+	offsetY := int32(float32(ui.winHeight) * 0.05)
+	return &sdl.Rect{offsetX, offsetY, dialogueWidth, dialogueHeight}
+}
+
+// DrawDialogue shows what the NPC is saying, and numbers the choices we can pick
+func (ui *ui) DrawDialogue(level *game.Level) {
+	conversation := level.Player.Conversation
+	choices := level.Player.AvailableChoices()
+
+	lines := 1 + len(conversation.Node.Text) + 1 + len(choices) // Name, text, gap, choices
+	dialogueRect := ui.getDialogueRect(lines)
+	ui.renderer.Copy(ui.eventBackground, nil, dialogueRect)
+
+	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A")
+	padding := int32(fontSizeY / 2)
+	x := dialogueRect.X + padding
+	y := dialogueRect.Y + padding
+	drawLine := func(s string, color sdl.Color) {
+		tex := ui.stringToTexture(s, color, FontSmall)
+		_, _, w, h, _ := tex.Query()
+		ui.renderer.Copy(tex, nil, &sdl.Rect{x, y, w, h})
+		y += int32(fontSizeY)
+	}
+
+	drawLine(conversation.NPC.Name+":", sdl.Color{255, 255, 0, 0})
+	for _, text := range conversation.Node.Text {
+		drawLine(text, sdl.Color{255, 255, 255, 0})
+	}
+	y += int32(fontSizeY)
+	for i, choice := range choices {
+		drawLine(strconv.Itoa(i+1)+". "+choice.Description, sdl.Color{255, 0, 0, 0})
+	}
+}
+
+// CheckDialogueChoice returns the index of the number key pressed, or -1
+func (ui *ui) CheckDialogueChoice() int {
+	for i := 0; i < 9; i++ {
+		if ui.keyDownOnce(uint8(sdl.SCANCODE_1) + uint8(i)) {
+			return i
+		}
+	}
+	return -1
+}
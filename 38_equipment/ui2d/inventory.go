@@ -47,46 +47,52 @@ func (ui *ui) getInventoryItemRect(i int) *sdl.Rect {
 func (ui *ui) DrawInventory(level *game.Level) {
 
 	// Enlarge player image
-	playerSrcRect := ui.textureIndex[level.Player.Rune][0]
 	invRect := ui.getInventoryRect()
 	ui.renderer.Copy(ui.groundInventoryBackground, nil, invRect)
 	offset := int32(float64(invRect.H) * 0.05) // Padding between inventory area and player
 
-	ui.renderer.Copy(ui.textureAtlas, &playerSrcRect, &sdl.Rect{invRect.X + invRect.X/4, invRect.Y + offset, invRect.W / 2, invRect.H / 2})
+	ui.drawSprite(ui.spriteNamed(playerSprite), &sdl.Rect{invRect.X + invRect.X/4, invRect.Y + offset, invRect.W / 2, invRect.H / 2})
 	ui.renderer.Copy(ui.slotBackground, nil, ui.getHelmetSlotRect())
 	ui.renderer.Copy(ui.slotBackground, nil, ui.getWeaponSlotRect())
 	// Render equipped helmet
 	if level.Player.Helmet != nil {
-		ui.renderer.Copy(ui.textureAtlas, &ui.textureIndex[level.Player.Helmet.Rune][0], ui.getHelmetSlotRect())
+		ui.drawSprite(ui.spriteNamed(level.Player.Helmet.Name), ui.getHelmetSlotRect())
 	}
 	// Render equipped weapon
 	if level.Player.Weapon != nil {
-		ui.renderer.Copy(ui.textureAtlas, &ui.textureIndex[level.Player.Weapon.Rune][0], ui.getWeaponSlotRect())
+		ui.drawSprite(ui.spriteNamed(level.Player.Weapon.Name), ui.getWeaponSlotRect())
 	}
 
 	// Render items in player inventory
-	for i, item := range level.Player.Items {
-		itemSrcRect := ui.textureIndex[item.Rune][0]
+	ui.drawDraggableItems(level.Player.Items, ui.getInventoryItemRect)
+}
+
+// drawDraggableItems draws items in their slots, except the one being dragged which follows the mouse
+func (ui *ui) drawDraggableItems(items []*game.Item, itemRect func(int) *sdl.Rect) {
+	for i, item := range items {
+		s := ui.spriteNamed(item.Name)
 
 		if item == ui.draggedItem {
 			itemSize := int32(itemSizeRatio * float32(ui.winWidth))
-			ui.renderer.Copy(ui.textureAtlas, &itemSrcRect, &sdl.Rect{int32(ui.currentMouseState.pos.X), int32(ui.currentMouseState.pos.Y), itemSize, itemSize})
+			ui.drawSprite(s, &sdl.Rect{int32(ui.currentMouseState.pos.X), int32(ui.currentMouseState.pos.Y), itemSize, itemSize})
 		} else {
-			ui.renderer.Copy(ui.textureAtlas, &itemSrcRect, ui.getInventoryItemRect(i))
+			ui.drawSprite(s, itemRect(i))
 		}
 	}
 }
 
 func (ui *ui) CheckInventoryItems(level *game.Level) *game.Item {
+	return ui.checkDraggableItems(level.Player.Items, ui.getInventoryItemRect)
+}
+
+// checkDraggableItems returns the item under the mouse while the left button is held
+func (ui *ui) checkDraggableItems(items []*game.Item, itemRect func(int) *sdl.Rect) *game.Item {
 	if ui.currentMouseState.leftButton {
 		// Dragged
-		mousePos := ui.currentMouseState.pos
-		for i, item := range level.Player.Items {
-			itemRect := ui.getInventoryItemRect(i)
-			// Check if current mouse position is in inventory rect
-			intersection := itemRect.HasIntersection(&sdl.Rect{int32(mousePos.X), int32(mousePos.Y), 1, 1}) // Pass mouse as a single pixel rect
-			if intersection {
-				// Clicked inventory item
+		for i, item := range items {
+			// Check if current mouse position is in item rect
+			if ui.mouseIn(itemRect(i)) {
+				// Clicked item
 				return item
 			}
 		}
@@ -95,6 +101,12 @@ func (ui *ui) CheckInventoryItems(level *game.Level) *game.Item {
 	return nil
 }
 
+// mouseIn checks if the current mouse position is inside a rect
+func (ui *ui) mouseIn(r *sdl.Rect) bool {
+	mousePos := ui.currentMouseState.pos
+	return r.HasIntersection(&sdl.Rect{int32(mousePos.X), int32(mousePos.Y), 1, 1}) // Pass mouse as a single pixel rect
+}
+
 func (ui *ui) CheckGroundItems(level *game.Level) *game.Item {
 	if !ui.currentMouseState.leftButton && ui.prevMouseState.leftButton {
 		// Clicked
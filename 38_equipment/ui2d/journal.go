@@ -0,0 +1,46 @@
+package ui2d
+
+import (
+	"github.com/maxproske/games-with-go/38_equipment/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func (ui *ui) getJournalRect() *sdl.Rect {
+	journalWidth := int32(float32(ui.winWidth) * 0.5)
+	journalHeight := int32(float32(ui.winHeight) * 0.6)
+	offsetX := (int32(ui.winWidth) - journalWidth) / 2
+	offsetY := (int32(ui.winHeight) - journalHeight) / 2
+	return &sdl.Rect{offsetX, offsetY, journalWidth, journalHeight}
+}
+
+// DrawJournal lists every quest the player has, with progress on each objective
+func (ui *ui) DrawJournal(level *game.Level) {
+	journalRect := ui.getJournalRect()
+	ui.renderer.Copy(ui.eventBackground, nil, journalRect)
+
+	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A")
+	x := journalRect.X + 10
+	y := journalRect.Y + 10
+	drawLine := func(s string, indent int32, color sdl.Color) {
+		tex := ui.stringToTexture(s, color, FontSmall)
+		_, _, w, h, _ := tex.Query()
+		ui.renderer.Copy(tex, nil, &sdl.Rect{x + indent, y, w, h})
+		y += int32(fontSizeY)
+	}
+
+	drawLine("Quests", 0, sdl.Color{255, 255, 0, 0})
+	if len(level.Player.Quests) == 0 {
+		drawLine("Nothing to do yet. Try talking to people.", 0, sdl.Color{255, 255, 255, 0})
+	}
+	for _, quest := range level.Player.Quests {
+		if quest.Done {
+			drawLine(quest.Name+" (complete)", 0, sdl.Color{128, 128, 128, 0})
+			continue
+		}
+		drawLine(quest.Name, 0, sdl.Color{255, 255, 255, 0})
+		drawLine(quest.Description, 20, sdl.Color{200, 200, 200, 0})
+		for _, objective := range quest.Objectives {
+			drawLine("- "+objective.String(), 20, sdl.Color{255, 0, 0, 0})
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package ui2d
+
+import (
+	"math"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Visible tiles start at ambientLight and lights add their colour on top.
+// Tiles we only remember stay grey, like before there were lights.
+const ambientLight = 0.35
+
+// lightKey is a light's reach, which only changes if the walls around it do
+type lightKey struct {
+	pos    game.Pos
+	radius int
+}
+
+// lighting caches how much of each light reaches each tile, and the colour of every tile this frame
+type lighting struct {
+	name   string
+	opaque [][]bool                          // What blocked light when the masks were made
+	masks  map[lightKey]map[game.Pos]float64 // Light to how strongly it reaches each tile
+	tiles  [][]sdl.Color
+}
+
+// blocksLight is canSeeThrough from the game, for tiles
+func blocksLight(tile game.Tile) bool {
+	switch tile.Rune {
+	case game.StoneWall, game.Blank:
+		return true
+	}
+	switch tile.OverlayRune {
+	case game.ClosedDoor, game.SecretDoor:
+		return true
+	}
+	return false
+}
+
+// opacityChanged is true on a new level, or when a door opened
+func (l *lighting) opacityChanged(level *game.Level) bool {
+	if l.name != level.Name || len(l.opaque) != len(level.Map) {
+		return true
+	}
+	for y, row := range level.Map {
+		if len(l.opaque[y]) != len(row) {
+			return true
+		}
+		for x, tile := range row {
+			if l.opaque[y][x] != blocksLight(tile) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// clearLine is true if nothing between two tiles blocks light, the tiles themselves can
+func clearLine(level *game.Level, from, to game.Pos) bool {
+	dx := to.X - from.X
+	dy := to.Y - from.Y
+	steps := int(math.Max(math.Abs(float64(dx)), math.Abs(float64(dy))))
+	for i := 1; i < steps; i++ {
+		x := from.X + int(math.Round(float64(dx*i)/float64(steps)))
+		y := from.Y + int(math.Round(float64(dy*i)/float64(steps)))
+		if blocksLight(level.Map[y][x]) {
+			return false
+		}
+	}
+	return true
+}
+
+// mask works out how strongly a light reaches the tiles around it, fading with distance
+func (l *lighting) mask(level *game.Level, key lightKey) map[game.Pos]float64 {
+	if m, ok := l.masks[key]; ok {
+		return m
+	}
+	m := make(map[game.Pos]float64)
+	r := key.radius
+	for y := key.pos.Y - r; y <= key.pos.Y+r; y++ {
+		for x := key.pos.X - r; x <= key.pos.X+r; x++ {
+			if y < 0 || y >= len(level.Map) || x < 0 || x >= len(level.Map[y]) {
+				continue
+			}
+			d := math.Hypot(float64(x-key.pos.X), float64(y-key.pos.Y))
+			if d > float64(r) {
+				continue
+			}
+			pos := game.Pos{x, y}
+			if !clearLine(level, key.pos, pos) {
+				continue
+			}
+			falloff := 1 - d/float64(r+1)
+			m[pos] = falloff * falloff
+		}
+	}
+	l.masks[key] = m
+	return m
+}
+
+// flicker wavers a flame's brightness, each one out of step with the rest
+func flicker(pos game.Pos, ticks uint32) float64 {
+	t := float64(ticks)
+	wave := math.Sin(t*0.011+float64(pos.X)*1.7) * math.Sin(t*0.023+float64(pos.Y)*2.3)
+	return 0.85 + 0.15*wave
+}
+
+// updateLighting colours every visible tile from the lights that reach it
+func (ui *ui) updateLighting(level *game.Level) {
+	l := &ui.lighting
+	if l.opacityChanged(level) {
+		l.name = level.Name
+		l.masks = make(map[lightKey]map[game.Pos]float64)
+		l.opaque = make([][]bool, len(level.Map))
+		for y, row := range level.Map {
+			l.opaque[y] = make([]bool, len(row))
+			for x, tile := range row {
+				l.opaque[y][x] = blocksLight(tile)
+			}
+		}
+	}
+
+	// Gather everything giving off light: torches, glowing things on the floor, and what players carry
+	type source struct {
+		light *game.Light
+		pos   game.Pos
+	}
+	sources := make([]source, 0)
+	for pos, light := range level.Lights {
+		sources = append(sources, source{light, pos})
+	}
+	for pos, items := range level.Items {
+		for _, item := range items {
+			if item.Light != nil {
+				sources = append(sources, source{item.Light, pos})
+			}
+		}
+	}
+	for _, player := range level.Players {
+		if player.Light != nil {
+			sources = append(sources, source{player.Light, player.Pos})
+		}
+		carried := append([]*game.Item{player.Weapon, player.Helmet}, player.Items...)
+		for _, item := range carried {
+			if item != nil && item.Light != nil {
+				sources = append(sources, source{item.Light, player.Pos})
+			}
+		}
+	}
+
+	light := make([][][3]float64, len(level.Map))
+	for y, row := range level.Map {
+		light[y] = make([][3]float64, len(row))
+		for x := range row {
+			light[y][x] = [3]float64{ambientLight, ambientLight, ambientLight}
+		}
+	}
+	now := sdl.GetTicks()
+	for _, s := range sources {
+		strength := 1.0
+		if s.light.Flicker {
+			strength = flicker(s.pos, now)
+		}
+		for pos, weight := range l.mask(level, lightKey{s.pos, s.light.Radius}) {
+			if !level.Map[pos.Y][pos.X].Visible {
+				continue
+			}
+			w := weight * strength / 255
+			light[pos.Y][pos.X][0] += float64(s.light.R) * w
+			light[pos.Y][pos.X][1] += float64(s.light.G) * w
+			light[pos.Y][pos.X][2] += float64(s.light.B) * w
+		}
+	}
+
+	l.tiles = make([][]sdl.Color, len(level.Map))
+	for y, row := range light {
+		l.tiles[y] = make([]sdl.Color, len(row))
+		for x, c := range row {
+			l.tiles[y][x] = sdl.Color{toByte(c[0]), toByte(c[1]), toByte(c[2]), 255}
+		}
+	}
+}
+
+func toByte(f float64) uint8 {
+	if f >= 1 {
+		return 255
+	}
+	return uint8(f * 255)
+}
+
+// lightAt is the colour to modulate the atlas with for a visible tile
+func (ui *ui) lightAt(pos game.Pos) sdl.Color {
+	tiles := ui.lighting.tiles
+	if pos.Y < 0 || pos.Y >= len(tiles) || pos.X < 0 || pos.X >= len(tiles[pos.Y]) {
+		return sdl.Color{255, 255, 255, 255}
+	}
+	return tiles[pos.Y][pos.X]
+}
+
+// tint lights up whatever we draw next on a tile
+func (ui *ui) tint(pos game.Pos) {
+	c := ui.lightAt(pos)
+	ui.textureAtlas.SetColorMod(c.R, c.G, c.B)
+}
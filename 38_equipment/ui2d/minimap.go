@@ -0,0 +1,213 @@
+package ui2d
+
+import (
+	"hash/fnv"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Minimap and full map, drawn one pixel per tile from what the player has seen
+
+// Fraction of the window the corner minimap is allowed to cover
+const minimapRatio = 0.2
+
+// minimap caches the map texture, so we only rebuild it when what we know about the level changes
+type minimap struct {
+	tex       *sdl.Texture
+	w, h      int
+	signature uint64
+
+	dst   sdl.Rect // Where we last drew the map, so it can be clicked on
+	scale float64
+
+	playerMarker *sdl.Texture
+	otherMarker  *sdl.Texture
+}
+
+// Colors for what we remember, and brighter for what we can see right now
+var (
+	mapWall    = sdl.Color{110, 110, 110, 255}
+	mapFloor   = sdl.Color{70, 55, 40, 255}
+	mapDoor    = sdl.Color{160, 100, 40, 255}
+	mapStair   = sdl.Color{255, 255, 0, 255}
+	mapPortal  = sdl.Color{255, 0, 255, 255}
+	mapItem    = sdl.Color{0, 200, 255, 255}
+	mapUnknown = sdl.Color{0, 0, 0, 0}
+	mapPlayer  = sdl.Color{0, 255, 0, 255}
+	mapOther   = sdl.Color{0, 100, 255, 255}
+)
+
+// How much brighter visible tiles are than remembered ones
+const mapVisibleUp = 60
+
+// mapSignature hashes everything the map texture is built from
+// Tiles we haven't seen don't count, so monsters moving in the dark don't cause a rebuild
+func mapSignature(level *game.Level) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(level.Name))
+	buf := make([]byte, 0, 16)
+	for y, row := range level.Map {
+		for x, tile := range row {
+			buf = buf[:0]
+			if !tile.Seen && !tile.Visible {
+				buf = append(buf, 0)
+				h.Write(buf)
+				continue
+			}
+			flags := byte(1)
+			if tile.Visible {
+				flags = 2
+			}
+			pos := game.Pos{x, y}
+			buf = append(buf, flags, byte(tile.Rune), byte(tile.OverlayRune), byte(len(level.Items[pos])))
+			if level.Portals[pos] != nil {
+				buf = append(buf, 1)
+			}
+			h.Write(buf)
+		}
+	}
+	return h.Sum64()
+}
+
+// mapColor picks what a known tile looks like on the map
+func mapColor(level *game.Level, pos game.Pos) sdl.Color {
+	tile := level.Map[pos.Y][pos.X]
+	if !tile.Seen && !tile.Visible {
+		return mapUnknown
+	}
+	var color sdl.Color
+	switch {
+	case tile.OverlayRune == game.SecretDoor:
+		color = mapWall // Its rune is the floor it hides, until it's found it looks like a wall, same as in the main view
+	case tile.OverlayRune == game.UpStair || tile.OverlayRune == game.DownStair:
+		return mapStair
+	case level.Portals[pos] != nil:
+		return mapPortal
+	case len(level.Items[pos]) > 0:
+		return mapItem
+	case tile.OverlayRune == game.ClosedDoor || tile.OverlayRune == game.OpenDoor || tile.Rune == game.OpenDoor:
+		color = mapDoor // Doors are overlays on the floor, except ones that start open
+	case tile.Rune == game.DirtFloor:
+		color = mapFloor
+	case tile.Rune == game.Blank:
+		return mapUnknown
+	default:
+		color = mapWall
+	}
+	if tile.Visible {
+		color.R = brighten(color.R)
+		color.G = brighten(color.G)
+		color.B = brighten(color.B)
+	}
+	return color
+}
+
+func brighten(c uint8) uint8 {
+	if int(c)+mapVisibleUp > 255 {
+		return 255
+	}
+	return c + uint8(mapVisibleUp)
+}
+
+// updateMinimap rebuilds the cached texture if the level or what we know about it changed
+func (ui *ui) updateMinimap(level *game.Level) {
+	mm := &ui.minimap
+	if mm.playerMarker == nil {
+		mm.playerMarker = ui.GetSinglePixelTex(&mapPlayer)
+		mm.otherMarker = ui.GetSinglePixelTex(&mapOther)
+	}
+	h := len(level.Map)
+	if h == 0 {
+		return
+	}
+	w := len(level.Map[0])
+	signature := mapSignature(level)
+	if mm.tex != nil && mm.w == w && mm.h == h && mm.signature == signature {
+		return
+	}
+
+	// New size means a new texture, eg. we took the stairs
+	if mm.tex == nil || mm.w != w || mm.h != h {
+		if mm.tex != nil {
+			mm.tex.Destroy()
+		}
+		tex, err := ui.renderer.CreateTexture(sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STATIC, int32(w), int32(h))
+		if err != nil {
+			panic(err)
+		}
+		err = tex.SetBlendMode(sdl.BLENDMODE_BLEND)
+		if err != nil {
+			panic(err)
+		}
+		mm.tex = tex
+		mm.w = w
+		mm.h = h
+	}
+
+	pixels := make([]byte, w*h*4)
+	bIndex := 0
+	for y, row := range level.Map {
+		for x := range row {
+			color := mapColor(level, game.Pos{x, y})
+			pixels[bIndex] = color.R
+			pixels[bIndex+1] = color.G
+			pixels[bIndex+2] = color.B
+			pixels[bIndex+3] = color.A
+			bIndex += 4
+		}
+	}
+	mm.tex.Update(nil, pixels, w*4)
+	mm.signature = signature
+}
+
+// drawMapAt stretches the cached map into a box, keeping tiles square, and marks where players are
+func (ui *ui) drawMapAt(level *game.Level, box *sdl.Rect) {
+	ui.updateMinimap(level)
+	mm := &ui.minimap
+	if mm.tex == nil {
+		return
+	}
+	scale := float64(box.W) / float64(mm.w)
+	if scaleY := float64(box.H) / float64(mm.h); scaleY < scale {
+		scale = scaleY
+	}
+	w := int32(float64(mm.w) * scale)
+	h := int32(float64(mm.h) * scale)
+	dst := &sdl.Rect{box.X + (box.W-w)/2, box.Y + (box.H-h)/2, w, h}
+	ui.renderer.Copy(ui.eventBackground, nil, dst)
+	ui.renderer.Copy(mm.tex, nil, dst)
+	mm.dst = *dst
+	mm.scale = scale
+
+	// Markers are at least 3 pixels, or you'd never find yourself on a small minimap
+	markerSize := int32(scale)
+	if markerSize < 3 {
+		markerSize = 3
+	}
+	marker := func(tex *sdl.Texture, pos game.Pos) {
+		x := dst.X + int32(float64(pos.X)*scale+scale/2) - markerSize/2
+		y := dst.Y + int32(float64(pos.Y)*scale+scale/2) - markerSize/2
+		ui.renderer.Copy(tex, nil, &sdl.Rect{x, y, markerSize, markerSize})
+	}
+	for _, player := range level.Players {
+		if player != level.Player && level.Map[player.Y][player.X].Visible {
+			marker(mm.otherMarker, player.Pos)
+		}
+	}
+	marker(mm.playerMarker, level.Player.Pos)
+}
+
+// DrawMinimap puts a small map in the top right corner
+func (ui *ui) DrawMinimap(level *game.Level) {
+	size := int32(float64(ui.winWidth) * minimapRatio)
+	margin := int32(5)
+	ui.drawMapAt(level, &sdl.Rect{int32(ui.winWidth) - size - margin, margin, size, size})
+}
+
+// DrawMap covers most of the window with the whole level, toggled with M
+func (ui *ui) DrawMap(level *game.Level) {
+	marginX := int32(float64(ui.winWidth) * 0.05)
+	marginY := int32(float64(ui.winHeight) * 0.05)
+	ui.drawMapAt(level, &sdl.Rect{marginX, marginY, int32(ui.winWidth) - 2*marginX, int32(ui.winHeight) - 2*marginY})
+}
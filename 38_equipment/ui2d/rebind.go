@@ -0,0 +1,134 @@
+package ui2d
+
+import (
+	"strings"
+
+	"github.com/maxproske/games-with-go/38_equipment/assets"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// The rebinding screen lists every action. Enter waits for a new key, button or stick push
+// to add to the selected action, Backspace clears it, and Escape saves and goes back.
+// These keys are fixed, so you can't rebind your way out of being able to rebind.
+
+type rebinder struct {
+	selected int    // Index into actions
+	waiting  bool   // The next key or button pressed is bound to the selected action
+	message  string // Why the last binding didn't work
+	changed  bool   // Save when we leave
+}
+
+// toggleBindings opens the rebinding screen, or saves and closes it
+func (ui *ui) toggleBindings() {
+	if ui.state != UIBindings {
+		ui.state = UIBindings
+		ui.rebind = rebinder{}
+		return
+	}
+	if ui.rebind.changed {
+		ui.bindings.save(assets.Path(bindingsFile))
+	}
+	ui.state = UIMain
+}
+
+// newBinding returns whatever key, button or axis was pressed this frame
+func (ui *ui) newBinding() (binding, bool) {
+	// keyDownOnce only goes up to 255, which covers every key on a normal keyboard
+	for code := 0; code < len(ui.keyboardState) && code < 256; code++ {
+		if ui.keyDownOnce(uint8(code)) {
+			return binding{keyBinding, code, 0}, true
+		}
+	}
+	for b := range ui.pad {
+		if ui.padPressed(b) {
+			return b, true
+		}
+	}
+	return binding{}, false
+}
+
+// updateRebind handles input while the rebinding screen is open
+func (ui *ui) updateRebind() {
+	rb := &ui.rebind
+	action := actions[rb.selected]
+	if rb.waiting {
+		b, ok := ui.newBinding()
+		if !ok {
+			return
+		}
+		rb.waiting = false
+		other := ui.bindings.conflict(b)
+		switch {
+		case other == action:
+			rb.message = b.String() + " already does " + action
+		case other != "":
+			rb.message = b.String() + " is already used by " + other + ", clear it there first"
+		default:
+			ui.bindings[action] = append(ui.bindings[action], b)
+			rb.changed = true
+			rb.message = ""
+		}
+		return
+	}
+
+	accept := binding{buttonBinding, int(sdl.CONTROLLER_BUTTON_A), 0}
+	back := binding{buttonBinding, int(sdl.CONTROLLER_BUTTON_B), 0}
+	switch {
+	case ui.keyDownOnce(sdl.SCANCODE_UP) || ui.actionPressed("Up"):
+		rb.selected = (rb.selected + len(actions) - 1) % len(actions)
+	case ui.keyDownOnce(sdl.SCANCODE_DOWN) || ui.actionPressed("Down"):
+		rb.selected = (rb.selected + 1) % len(actions)
+	case ui.keyDownOnce(sdl.SCANCODE_RETURN) || ui.padPressed(accept):
+		rb.waiting = true
+		rb.message = ""
+	case ui.keyDownOnce(sdl.SCANCODE_BACKSPACE):
+		if action == "Bindings" {
+			rb.message = "Bindings always needs a key, add another one instead"
+			return
+		}
+		ui.bindings[action] = nil
+		rb.changed = true
+		rb.message = ""
+	case ui.keyDownOnce(sdl.SCANCODE_ESCAPE) || ui.padPressed(back) || ui.actionPressed("Bindings"):
+		ui.toggleBindings()
+	}
+}
+
+// DrawBindings shows every action and what it is bound to
+func (ui *ui) DrawBindings() {
+	rect := ui.getJournalRect()
+	rect.Y -= rect.H / 3 // Taller than the journal, there's a lot to list
+	rect.H += rect.H * 2 / 3
+	ui.renderer.Copy(ui.eventBackground, nil, rect)
+
+	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A")
+	x := rect.X + 10
+	y := rect.Y + 10
+	drawLine := func(s string, color sdl.Color) {
+		tex := ui.stringToTexture(s, color, FontSmall)
+		_, _, w, h, _ := tex.Query()
+		ui.renderer.Copy(tex, nil, &sdl.Rect{x, y, w, h})
+		y += int32(fontSizeY)
+	}
+
+	drawLine("Bindings: Enter adds, Backspace clears, Escape saves", sdl.Color{255, 255, 0, 0})
+	for i, action := range actions {
+		names := make([]string, 0, len(ui.bindings[action]))
+		for _, b := range ui.bindings[action] {
+			names = append(names, b.String())
+		}
+		line := action + ": " + strings.Join(names, ", ")
+		color := sdl.Color{200, 200, 200, 0}
+		if i == ui.rebind.selected {
+			color = sdl.Color{255, 255, 255, 0}
+			line = "> " + line
+			if ui.rebind.waiting {
+				line += " ... press something"
+			}
+		}
+		drawLine(line, color)
+	}
+	if ui.rebind.message != "" {
+		drawLine(ui.rebind.message, sdl.Color{255, 0, 0, 0})
+	}
+}
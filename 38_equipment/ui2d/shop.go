@@ -0,0 +1,91 @@
+package ui2d
+
+import (
+	"strconv"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Merchant's stock on the left, player's inventory on the right
+func (ui *ui) getShopRect() *sdl.Rect {
+	return &sdl.Rect{int32(float32(ui.winWidth) * 0.1), int32(float32(ui.winHeight) * 0.15), int32(float32(ui.winWidth) * 0.38), int32(float32(ui.winHeight) * 0.6)}
+}
+
+func (ui *ui) getShopPlayerRect() *sdl.Rect {
+	shopRect := ui.getShopRect()
+	return &sdl.Rect{int32(float32(ui.winWidth) * 0.52), shopRect.Y, shopRect.W, shopRect.H}
+}
+
+// Lay items out in rows below the panel's title
+func (ui *ui) getPanelItemRect(panel *sdl.Rect, i int) *sdl.Rect {
+	itemSize := int32(itemSizeRatio * float32(ui.winWidth))
+	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A")
+	perRow := int(panel.W / itemSize)
+	return &sdl.Rect{panel.X + int32(i%perRow)*itemSize, panel.Y + int32(fontSizeY*2) + int32(i/perRow)*itemSize, itemSize, itemSize}
+}
+
+func (ui *ui) getShopItemRect(i int) *sdl.Rect {
+	return ui.getPanelItemRect(ui.getShopRect(), i)
+}
+
+func (ui *ui) getShopPlayerItemRect(i int) *sdl.Rect {
+	return ui.getPanelItemRect(ui.getShopPlayerRect(), i)
+}
+
+// DrawShop shows the merchant's stock next to the player's inventory
+func (ui *ui) DrawShop(level *game.Level) {
+	merchant := level.Player.Trading
+	shopRect := ui.getShopRect()
+	playerRect := ui.getShopPlayerRect()
+	ui.renderer.Copy(ui.groundInventoryBackground, nil, shopRect)
+	ui.renderer.Copy(ui.groundInventoryBackground, nil, playerRect)
+
+	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A")
+	drawText := func(s string, x, y int32) {
+		tex := ui.stringToTexture(s, sdl.Color{255, 255, 0, 0}, FontSmall)
+		_, _, w, h, _ := tex.Query()
+		ui.renderer.Copy(tex, nil, &sdl.Rect{x, y, w, h})
+	}
+	drawText(merchant.Name+" (drag to buy)", shopRect.X+5, shopRect.Y+5)
+	drawText(level.Player.Name+": "+strconv.Itoa(level.Player.Gold)+" gold (drag to sell)", playerRect.X+5, playerRect.Y+5)
+
+	ui.drawDraggableItems(merchant.Shop.Stock, ui.getShopItemRect)
+	ui.drawDraggableItems(level.Player.Items, ui.getShopPlayerItemRect)
+
+	// Show the price of whatever is under the mouse
+	for i, item := range merchant.Shop.Stock {
+		if ui.mouseIn(ui.getShopItemRect(i)) {
+			drawText(item.Name+": "+strconv.Itoa(item.Value)+" gold", shopRect.X+5, shopRect.Y+shopRect.H-int32(fontSizeY)-5)
+		}
+	}
+	for i, item := range level.Player.Items {
+		if ui.mouseIn(ui.getShopPlayerItemRect(i)) {
+			drawText(item.Name+": sells for "+strconv.Itoa(item.SellPrice())+" gold", playerRect.X+5, playerRect.Y+playerRect.H-int32(fontSizeY)-5)
+		}
+	}
+}
+
+// CheckShopItems returns the item being dragged from either side of the shop
+func (ui *ui) CheckShopItems(level *game.Level) *game.Item {
+	item := ui.checkDraggableItems(level.Player.Trading.Shop.Stock, ui.getShopItemRect)
+	if item == nil {
+		item = ui.checkDraggableItems(level.Player.Items, ui.getShopPlayerItemRect)
+	}
+	return item
+}
+
+// CheckTradedItem works out if the dragged item was dropped on the other side of the shop
+func (ui *ui) CheckTradedItem(level *game.Level) game.InputType {
+	for _, item := range level.Player.Trading.Shop.Stock {
+		if item == ui.draggedItem && ui.mouseIn(ui.getShopPlayerRect()) {
+			return game.Buy
+		}
+	}
+	for _, item := range level.Player.Items {
+		if item == ui.draggedItem && ui.mouseIn(ui.getShopRect()) {
+			return game.Sell
+		}
+	}
+	return game.None
+}
@@ -0,0 +1,175 @@
+package ui2d
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/maxproske/games-with-go/38_equipment/assets"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Players are all drawn the same, their names are whatever they chose
+const playerSprite = "Player"
+
+// sprite is a named picture in the atlas, with variations to pick from or frames to animate
+type sprite struct {
+	name      string
+	rects     []sdl.Rect
+	frameTime uint32    // Milliseconds each frame shows for, 0 if the rects are variations
+	auto      *autotile // Picks a rect from its neighbours instead
+}
+
+// frame is the picture to draw now, the first one unless the sprite is animated
+func (s *sprite) frame(now uint32) sdl.Rect {
+	if s.frameTime == 0 {
+		return s.rects[0]
+	}
+	return s.rects[(now/s.frameTime)%uint32(len(s.rects))]
+}
+
+// loadAtlas reads the atlas index, which names every sprite in tiles.png, see assets/ui/atlas.txt for the format
+func (ui *ui) loadAtlas() {
+	_, _, atlasWidth, _, err := ui.textureAtlas.Query()
+	if err != nil {
+		panic(err)
+	}
+	ui.sprites, ui.runeSprites = parseAtlas(assets.UI.MustRead(atlasFile), atlasWidth)
+}
+
+// parseAtlas reads sprites from an atlas index, atlasWidth is how wide tiles.png is for wrapping cells
+func parseAtlas(data []byte, atlasWidth int32) (map[string]*sprite, map[rune]*sprite) {
+	sprites := make(map[string]*sprite)
+	runeSprites := make(map[rune]*sprite)
+
+	var current *sprite
+	autoMode := ""
+	finish := func() {
+		if current == nil {
+			return
+		}
+		if len(current.rects) == 0 {
+			panic("Sprite " + current.name + " has no pictures in " + atlasFile)
+		}
+		switch {
+		case autoMode == "":
+		case autoMode == "auto4" && len(current.rects) == 16:
+			current.auto = &autotile{4, current.rects}
+		case autoMode == "auto8" && len(current.rects) == 47:
+			current.auto = &autotile{8, current.rects}
+		default:
+			panic("Invalid autotile set " + current.name + ", auto4 needs 16 pictures and auto8 needs 47")
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '@' {
+			finish()
+			name := strings.TrimSpace(line[1:])
+			if _, exists := sprites[name]; exists {
+				panic("Sprite " + name + " is in " + atlasFile + " twice")
+			}
+			current = &sprite{name: name}
+			autoMode = ""
+			sprites[name] = current
+			continue
+		}
+		if current == nil {
+			panic("Line before first sprite in " + atlasFile + ": " + line)
+		}
+
+		keyword, value := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			keyword, value = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		switch keyword {
+		case "rect":
+			n := parseInts(value, 4, line)
+			current.rects = append(current.rects, sdl.Rect{n[0], n[1], n[2], n[3]})
+		case "cells":
+			n := parseInts(value, 3, line)
+			x, y := n[0], n[1]
+			columns := atlasWidth / atlasTileSize
+			for i := int32(0); i < n[2]; i++ {
+				current.rects = append(current.rects, sdl.Rect{x * atlasTileSize, y * atlasTileSize, atlasTileSize, atlasTileSize})
+				// Wrap around if varied images continue on a new line
+				x++
+				if x >= columns {
+					x = 0
+					y++
+				}
+			}
+		case "frames":
+			ms, err := strconv.Atoi(value)
+			if err != nil || ms <= 0 {
+				panic("Invalid frame time in " + atlasFile + ": " + line)
+			}
+			current.frameTime = uint32(ms)
+		case "rune":
+			r := []rune(value)
+			if len(r) != 1 {
+				panic("rune needs a single character in " + atlasFile + ": " + line)
+			}
+			if other, exists := runeSprites[r[0]]; exists {
+				panic("Rune " + value + " is used by both " + other.name + " and " + current.name)
+			}
+			runeSprites[r[0]] = current
+		case "auto4", "auto8":
+			autoMode = keyword
+		default:
+			panic("Invalid line in " + atlasFile + ": " + line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		panic(err)
+	}
+	finish()
+	return sprites, runeSprites
+}
+
+// parseInts reads n comma separated numbers, eg. "10,18,12"
+func parseInts(s string, n int, line string) []int32 {
+	fields := strings.Split(s, ",")
+	if len(fields) != n {
+		panic("Expected " + strconv.Itoa(n) + " numbers in " + atlasFile + ": " + line)
+	}
+	ints := make([]int32, n)
+	for i, f := range fields {
+		v, err := strconv.ParseInt(strings.TrimSpace(f), 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		ints[i] = int32(v)
+	}
+	return ints
+}
+
+// spriteNamed finds the sprite something in the game is drawn with, eg. "Rat" or "Sword"
+func (ui *ui) spriteNamed(name string) *sprite {
+	s, ok := ui.sprites[name]
+	if !ok {
+		panic("No sprite named " + name + " in " + atlasFile)
+	}
+	return s
+}
+
+// tileSprite finds the sprite a map rune is drawn with
+func (ui *ui) tileSprite(r rune) *sprite {
+	s, ok := ui.runeSprites[r]
+	if !ok {
+		panic("No sprite for map rune " + string(r) + " in " + atlasFile)
+	}
+	return s
+}
+
+// drawSprite draws the current picture of a sprite, for things that don't animate across tiles
+func (ui *ui) drawSprite(s *sprite, dst *sdl.Rect) {
+	srcRect := s.frame(sdl.GetTicks())
+	ui.renderer.Copy(ui.textureAtlas, &srcRect, dst)
+}
@@ -0,0 +1,69 @@
+package ui2d
+
+import (
+	"github.com/maxproske/games-with-go/38_equipment/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Milliseconds between travel steps, so you can watch where you're going
+const travelDelay = 60
+
+// CheckMapClick turns a left click on the level, the minimap or the full map into a tile to travel to
+func (ui *ui) CheckMapClick(level *game.Level) (game.Pos, bool) {
+	if ui.currentMouseState.leftButton || !ui.prevMouseState.leftButton {
+		return game.Pos{}, false // Not clicked
+	}
+	if ui.draggedItem != nil || level.Player.Trading != nil || level.Player.Conversation != nil {
+		return game.Pos{}, false
+	}
+	if ui.state != UIMain && ui.state != UIMap {
+		return game.Pos{}, false
+	}
+	mouse := &sdl.Rect{int32(ui.currentMouseState.pos.X), int32(ui.currentMouseState.pos.Y), 1, 1}
+
+	var pos game.Pos
+	mm := &ui.minimap
+	if mm.tex != nil && mm.dst.HasIntersection(mouse) {
+		pos = game.Pos{int(float64(mouse.X-mm.dst.X) / mm.scale), int(float64(mouse.Y-mm.dst.Y) / mm.scale)}
+	} else if ui.state == UIMap {
+		return game.Pos{}, false // Clicked next to the map
+	} else {
+		// Leave clicks on the event console and the ground items alone
+		textStart := int32(float64(ui.winHeight) * 0.68)
+		textWidth := int32(float64(ui.winWidth) * 0.25)
+		if (&sdl.Rect{0, textStart, textWidth, int32(ui.winHeight) - textStart}).HasIntersection(mouse) {
+			return game.Pos{}, false
+		}
+		itemSize := int32(itemSizeRatio * float32(ui.winWidth))
+		if mouse.Y >= int32(ui.winHeight)-itemSize && mouse.X >= int32(float64(ui.winWidth)*0.9) {
+			return game.Pos{}, false
+		}
+		// Undo the camera offsets Draw used
+		x := mouse.X - ui.offsetX
+		y := mouse.Y - ui.offsetY
+		if x < 0 || y < 0 {
+			return game.Pos{}, false
+		}
+		pos = game.Pos{int(x) / ui.tileSize, int(y) / ui.tileSize}
+	}
+
+	if pos.Y >= len(level.Map) || pos.X >= len(level.Map[pos.Y]) {
+		return game.Pos{}, false
+	}
+	tile := level.Map[pos.Y][pos.X]
+	if !tile.Seen || pos == level.Player.Pos {
+		return game.Pos{}, false
+	}
+	return pos, true
+}
+
+// keepTraveling asks for the next step of the route, once for each level we're sent
+func (ui *ui) keepTraveling() {
+	now := sdl.GetTicks()
+	if ui.stepSent || now-ui.lastStep < travelDelay {
+		return
+	}
+	ui.stepSent = true
+	ui.lastStep = now
+	ui.Send(&game.Input{Typ: game.KeepTraveling})
+}
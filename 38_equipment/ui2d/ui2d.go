@@ -1,13 +1,13 @@
 package ui2d
 
 import (
-	"bufio"
+	"bytes"
 	"image/png"
 	"math/rand"
-	"os"
 	"strconv"
-	"strings"
+	"sync"
 
+	"github.com/maxproske/games-with-go/38_equipment/assets"
 	"github.com/maxproske/games-with-go/38_equipment/game"
 	"github.com/veandco/go-sdl2/mix"
 	"github.com/veandco/go-sdl2/sdl"
@@ -53,25 +53,34 @@ type uiState int
 const (
 	UIMain uiState = iota
 	UIInventory
+	UIJournal
+	UIMap
+	UIBindings
 )
 
 type ui struct {
+	game.Channels             // Where levels come from and inputs go
 	state             uiState // Main or inventory
 	draggedItem       *game.Item
 	sounds            sounds
 	winWidth          int
 	winHeight         int
+	zoom              int // Tiles are drawn zoom times their size in the atlas
+	tileSize          int // Size of a tile on screen
 	renderer          *sdl.Renderer
 	window            *sdl.Window
-	textureAtlas      *sdl.Texture        // Spritesheets called texture atlases
-	textureIndex      map[rune][]sdl.Rect // Go map from a tile to rect
+	textureAtlas      *sdl.Texture       // Spritesheets called texture atlases
+	sprites           map[string]*sprite // Named pictures in the atlas, see sprites.go
+	runeSprites       map[rune]*sprite   // Which sprite map tiles are drawn with
+	tileCache         tileCache
+	lighting          lighting
 	prevKeyboardState []uint8
 	keyboardState     []uint8
 	centerX           int // Keep camera centered around player
 	centerY           int
-	r                 *rand.Rand       // RNG should not be shared aross UIs
-	levelChan         chan *game.Level // What level it's getting data from
-	inputChan         chan *game.Input
+	offsetX           int32 // Camera offsets from the last Draw, to turn clicks into tiles
+	offsetY           int32
+	r                 *rand.Rand // RNG should not be shared aross UIs
 	fontSmall         *ttf.Font
 	fontMedium        *ttf.Font
 	fontLarge         *ttf.Font
@@ -86,27 +95,52 @@ type ui struct {
 
 	currentMouseState *mouseState
 	prevMouseState    *mouseState
+
+	minimap minimap
+
+	stepSent bool   // Already asked to keep traveling on the level we have
+	lastStep uint32 // Ticks when we last did, so travel doesn't happen in a blink
+
+	bindings    bindings
+	controllers map[sdl.JoystickID]*sdl.GameController // Open controllers by instance id
+	held        map[string]bool                        // Actions held down this frame
+	prevHeld    map[string]bool
+	fired       map[string]bool   // Actions that happen this frame
+	repeatAt    map[string]uint32 // Ticks when a held action happens again
+	pad         map[binding]bool  // Controller buttons and axes held this frame
+	prevPad     map[binding]bool
+	rebind      rebinder
+
+	anims     []*anim    // Movement, attacks and hits still playing
+	floaters  []*floater // Damage numbers still drifting up
+	healthBar *sdl.Texture
+
+	assetChanges <-chan string // Files edited while playing, see assets.go
+	fontData     []byte        // Files SDL reads from as it goes
+	musicData    []byte
 }
 
 // NewUI creates our UI struct
-func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
+func NewUI(channels game.Channels) *ui {
+	sdlOnce.Do(initSDL)
 	ui := &ui{}
 	ui.state = UIMain
 	ui.str2TexSmall = make(map[string]*sdl.Texture)
 	ui.str2TexMedium = make(map[string]*sdl.Texture)
 	ui.str2TexLarge = make(map[string]*sdl.Texture)
-	ui.inputChan = inputChan
-	ui.levelChan = levelChan
+	ui.Channels = channels
 	ui.r = rand.New(rand.NewSource(1)) // Each UI has its own random starting with the same seed
 	ui.winHeight = 720
 	ui.winWidth = 1280
+	ui.setZoom(1)
 
 	// Create a window.
-	window, err := sdl.CreateWindow("RPG", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, int32(ui.winWidth), int32(ui.winHeight), sdl.WINDOW_SHOWN)
+	window, err := sdl.CreateWindow("RPG", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, int32(ui.winWidth), int32(ui.winHeight), sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
 	if err != nil {
 		panic(err)
 	}
 	ui.window = window
+	ui.window.SetMinimumSize(minWinWidth, minWinHeight)
 
 	// Create renderer.
 	ui.renderer, err = sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
@@ -118,8 +152,9 @@ func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
 	//sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "1")
 
 	// Create texture.
-	ui.textureAtlas = ui.imgFileToTexture("../22_texture_index/ui2d/assets/tiles.png")
-	ui.loadTextureIndex()
+	ui.textureAtlas = ui.imgFileToTexture(tilesFile)
+	ui.loadAtlas()
+	ui.assetChanges = assets.Watch(tilesFile, atlasFile)
 
 	// Update keyboard state
 	ui.keyboardState = sdl.GetKeyboardState() // Updates by sdl
@@ -128,23 +163,17 @@ func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
 		ui.prevKeyboardState[i] = v
 	}
 
+	// Read which keys and buttons do what
+	ui.bindings = loadBindings(assets.Path(bindingsFile))
+	ui.controllers = make(map[sdl.JoystickID]*sdl.GameController)
+	ui.repeatAt = make(map[string]uint32)
+
 	// Uninitialize center pos
 	ui.centerX = -1
 	ui.centerY = -1
 
-	// Get the font sizes
-	ui.fontSmall, err = ttf.OpenFont("../29_fonts/ui2d/assets/gothic.ttf", int(float64(ui.winWidth)*0.015))
-	if err != nil {
-		panic(err)
-	}
-	ui.fontMedium, err = ttf.OpenFont("../29_fonts/ui2d/assets/gothic.ttf", 32)
-	if err != nil {
-		panic(err)
-	}
-	ui.fontLarge, err = ttf.OpenFont("../29_fonts/ui2d/assets/gothic.ttf", 64)
-	if err != nil {
-		panic(err)
-	}
+	// Get the font sizes, and again whenever the window changes size
+	ui.loadFonts()
 
 	// Draw console background
 	ui.eventBackground = ui.GetSinglePixelTex(&sdl.Color{0, 0, 0, 128})
@@ -156,38 +185,24 @@ func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
 	ui.slotBackground = ui.GetSinglePixelTex(&sdl.Color{0, 0, 0, 255})
 	ui.slotBackground.SetBlendMode(sdl.BLENDMODE_BLEND)
 
+	ui.healthBar = ui.GetSinglePixelTex(&sdl.Color{0, 200, 0, 255})
+
 	// Start playing music
 	err = mix.OpenAudio(22050, mix.DEFAULT_FORMAT, 2, 4096)
 	if err != nil {
 		panic(err)
 	}
-	mus, err := mix.LoadMUS("../34_music/ui2d/assets/ambient.ogg")
-	if err != nil {
-		panic(err)
-	}
+	mus := ui.loadMusic("ambient.ogg")
 	mus.Play(-1) // Loop forever
 
 	// Load footstep sounds
-	footstepBase := "../34_music/ui2d/assets/footstep0"
 	for i := 0; i < 10; i++ {
-		footstepFile := footstepBase + strconv.Itoa(i) + ".ogg"
-		footstepSound, err := mix.LoadWAV(footstepFile)
-		if err != nil {
-			panic(err)
-		}
+		footstepSound := loadSound("footstep0" + strconv.Itoa(i) + ".ogg")
 		ui.sounds.footsteps = append(ui.sounds.footsteps, footstepSound) // We can append without having to make the door
 	}
 	// Load door sounds
-	doorOpen1, err := mix.LoadWAV("../34_music/ui2d/assets/doorOpen_1.ogg")
-	if err != nil {
-		panic(err)
-	}
-	ui.sounds.openingDoors = append(ui.sounds.openingDoors, doorOpen1)
-	doorOpen2, err := mix.LoadWAV("../34_music/ui2d/assets/doorOpen_2.ogg")
-	if err != nil {
-		panic(err)
-	}
-	ui.sounds.openingDoors = append(ui.sounds.openingDoors, doorOpen2)
+	ui.sounds.openingDoors = append(ui.sounds.openingDoors, loadSound("doorOpen_1.ogg"))
+	ui.sounds.openingDoors = append(ui.sounds.openingDoors, loadSound("doorOpen_2.ogg"))
 
 	return ui
 }
@@ -254,59 +269,9 @@ func (ui *ui) stringToTexture(s string, color sdl.Color, size FontSize) *sdl.Tex
 	return tex
 }
 
-func (ui *ui) loadTextureIndex() {
-	ui.textureIndex = make(map[rune][]sdl.Rect)
-	infile, err := os.Open("ui2d/assets/atlas-index.txt")
-	if err != nil {
-		panic(err)
-	}
-	defer infile.Close()
-
-	// Read from scanner
-	scanner := bufio.NewScanner(infile) // *File satisfies io.Reader interface
-	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimSpace(line) // Remove extra spaces
-		tileRune := rune(line[0])      // Get first rune from the string
-		xy := line[1:]                 // Get ButFirst
-		splitXYC := strings.Split(xy, ",")
-		x, err := strconv.ParseInt(strings.TrimSpace(splitXYC[0]), 10, 64) // base10, bit size 64
-		if err != nil {
-			panic(err)
-		}
-		y, err := strconv.ParseInt(strings.TrimSpace(splitXYC[1]), 10, 64)
-		if err != nil {
-			panic(err)
-		}
-		// Tile variation
-		variationCount, err := strconv.ParseInt(strings.TrimSpace(splitXYC[2]), 10, 64)
-		if err != nil {
-			panic(err)
-		}
-		var rects []sdl.Rect
-		for i := int64(0); i < variationCount; i++ {
-			rects = append(rects, sdl.Rect{int32(x * 32), int32(y * 32), 32, 32})
-			// Wrap around if varied images continue on a new line
-			x++
-			if x > 62 {
-				x = 0
-				y++
-			}
-		}
-		ui.textureIndex[tileRune] = rects
-	}
-}
-
 func (ui *ui) imgFileToTexture(filename string) *sdl.Texture {
-	// Open
-	infile, err := os.Open(filename)
-	if err != nil {
-		panic(err)
-	}
-	defer infile.Close()
-
 	// Decode
-	img, err := png.Decode(infile)
+	img, err := png.Decode(bytes.NewReader(assets.UI.MustRead(filename)))
 	if err != nil {
 		panic(err)
 	}
@@ -349,6 +314,15 @@ func (ui *ui) imgFileToTexture(filename string) *sdl.Texture {
 
 // Init callback runs before anything else
 func init() {
+	game.RegisterFrontend("sdl", func(channels game.Channels) game.Frontend {
+		return NewUI(channels)
+	})
+}
+
+// initSDL waits until the first window is made, so importing ui2d works without a display
+var sdlOnce sync.Once
+
+func initSDL() {
 	// Initialize SDL2.
 	err := sdl.Init(sdl.INIT_EVERYTHING)
 	if err != nil {
@@ -389,22 +363,25 @@ func (ui *ui) Draw(level *game.Level) {
 	}
 
 	// Center based on width and height of screen
-	offsetX := int32((ui.winWidth / 2) - ui.centerX*32) // Cast int to int32 since we will always use it as int32
-	offsetY := int32((ui.winHeight / 2) - ui.centerY*32)
+	ts := int32(ui.tileSize)
+	offsetX := int32((ui.winWidth / 2) - ui.centerX*ui.tileSize) // Cast int to int32 since we will always use it as int32
+	offsetY := int32((ui.winHeight / 2) - ui.centerY*ui.tileSize)
+	ui.offsetX = offsetX
+	ui.offsetY = offsetY
 
 	// Clear before drawing tiles
 	ui.renderer.Clear()
 
-	// Set reproducable seed
-	// If tiles change during gameplay, we're not calling ui.R.Intn the same number of times
-	ui.r.Seed(1)
+	// Sprites are chosen once per level, see autotile.go
+	tiles := ui.tiles(level)
+	ui.updateLighting(level)
+	now := sdl.GetTicks()
 	for y, row := range level.Map {
 		for x, tile := range row {
 			if tile.Rune != game.Blank {
-				srcRects := ui.textureIndex[tile.Rune]
-				srcRect := srcRects[ui.r.Intn(len(srcRects))] // Random number between 1 and length of variations
+				srcRect := tiles.rect(x, y, now)
 				if tile.Visible || tile.Seen {
-					dstRect := sdl.Rect{int32(x*32) + offsetX, int32(y*32) + offsetY, 32, 32}
+					dstRect := sdl.Rect{int32(x)*ts + offsetX, int32(y)*ts + offsetY, ts, ts}
 
 					// If debug map contains position we are about to draw, set color
 					pos := game.Pos{x, y}
@@ -413,44 +390,65 @@ func (ui *ui) Draw(level *game.Level) {
 					} else if tile.Seen && !tile.Visible {
 						ui.textureAtlas.SetColorMod(128, 128, 128) // Halfway faded out
 					} else {
-						ui.textureAtlas.SetColorMod(255, 255, 255) // No longer any changes to the texture
+						ui.tint(pos) // Lit by torches and lamps, see lighting.go
 					}
 
 					ui.renderer.Copy(ui.textureAtlas, &srcRect, &dstRect)
 
 					if tile.OverlayRune != game.Blank {
 						// TODO(max): Support multiple door varients
-						srcRect := ui.textureIndex[tile.OverlayRune][0]
-						ui.renderer.Copy(ui.textureAtlas, &srcRect, &dstRect) //  Reuse dstrects since this is an overlay
+						ui.drawSprite(ui.tileSprite(tile.OverlayRune), &dstRect) //  Reuse dstrects since this is an overlay
 					}
 				}
 			}
 		}
 	}
 
-	ui.textureAtlas.SetColorMod(255, 255, 255) // No colour mods on monsters or items
+	// Draw traps we know about
+	for pos, trap := range level.Traps {
+		if level.Map[pos.Y][pos.X].Visible && !trap.Hidden {
+			ui.tint(pos)
+			ui.drawSprite(ui.spriteNamed(trap.Name), &sdl.Rect{int32(pos.X)*ts + offsetX, int32(pos.Y)*ts + offsetY, ts, ts})
+		}
+	}
 
 	// Draw items
 	for pos, items := range level.Items {
 		if level.Map[pos.Y][pos.X].Visible {
+			ui.tint(pos)
 			for _, item := range items {
-				itemSrcRect := ui.textureIndex[item.Rune][0]
-				ui.renderer.Copy(ui.textureAtlas, &itemSrcRect, &sdl.Rect{int32(pos.X)*32 + offsetX, int32(pos.Y)*32 + offsetY, 32, 32})
+				ui.drawSprite(ui.spriteNamed(item.Name), &sdl.Rect{int32(pos.X)*ts + offsetX, int32(pos.Y)*ts + offsetY, ts, ts})
 			}
 		}
 	}
 
-	// Draw monsters
+	// Draw monsters, sliding and lunging as they go
+	ui.expireAnims()
 	for pos, monster := range level.Monsters {
 		if level.Map[pos.Y][pos.X].Visible {
-			monsterSrcRect := ui.textureIndex[monster.Rune][0]
-			ui.renderer.Copy(ui.textureAtlas, &monsterSrcRect, &sdl.Rect{int32(pos.X)*32 + offsetX, int32(pos.Y)*32 + offsetY, 32, 32})
+			ui.drawEntity(ui.spriteNamed(monster.Name), pos)
+			ui.drawHealthBar(monster)
+		}
+	}
+
+	// Draw NPCs
+	for pos, npc := range level.NPCs {
+		if level.Map[pos.Y][pos.X].Visible {
+			ui.drawEntity(ui.spriteNamed(npc.Name), pos)
+		}
+	}
+
+	// Draw everyone else playing on this level
+	for _, player := range level.Players {
+		if player != level.Player && level.Map[player.Y][player.X].Visible {
+			ui.drawEntity(ui.spriteNamed(playerSprite), player.Pos)
 		}
 	}
 
 	// Draw player
-	playerSrcRect := ui.textureIndex[level.Player.Rune][0]
-	ui.renderer.Copy(ui.textureAtlas, &playerSrcRect, &sdl.Rect{int32(level.Player.X)*32 + offsetX, int32(level.Player.Y)*32 + offsetY, 32, 32})
+	ui.drawEntity(ui.spriteNamed(playerSprite), level.Player.Pos)
+	ui.textureAtlas.SetColorMod(255, 255, 255) // No colour mods on the inventory
+	ui.drawFloaters()
 
 	// Draw event console background
 	// nil for the source stretches one pixel to our dst
@@ -477,6 +475,15 @@ func (ui *ui) Draw(level *game.Level) {
 		}
 	}
 
+	// Draw gold counter
+	goldTex := ui.stringToTexture("Gold: "+strconv.Itoa(level.Player.Gold), sdl.Color{255, 255, 0, 0}, FontSmall)
+	_, _, goldW, goldH, _ := goldTex.Query()
+	ui.renderer.Copy(goldTex, nil, &sdl.Rect{5, 5, goldW, goldH})
+
+	if ui.state != UIMap {
+		ui.DrawMinimap(level)
+	}
+
 	// Render Inventory UI
 	groundInvStart := int32(float64(ui.winWidth) * 0.9)
 	groundInvWidth := int32(ui.winWidth) - groundInvStart
@@ -485,9 +492,8 @@ func (ui *ui) Draw(level *game.Level) {
 
 	items := level.Items[level.Player.Pos]
 	for i, item := range items {
-		itemSrcRect := ui.textureIndex[item.Rune][0]
 		// Right to left
-		ui.renderer.Copy(ui.textureAtlas, &itemSrcRect, ui.getGroundItemRect(i))
+		ui.drawSprite(ui.spriteNamed(item.Name), ui.getGroundItemRect(i))
 	}
 
 	//ui.renderer.Present()
@@ -536,40 +542,63 @@ func (ui *ui) Run() {
 			switch e := event.(type) {
 			case *sdl.QuitEvent:
 				// Instead of returning, put inputn into channel
-				ui.inputChan <- &game.Input{Typ: game.QuitGame}
+				ui.Send(&game.Input{Typ: game.QuitGame})
 			case *sdl.WindowEvent:
-				if e.Event == sdl.WINDOWEVENT_CLOSE {
-					ui.inputChan <- &game.Input{Typ: game.CloseWindow, LevelChannel: ui.levelChan} // Let game close that level channel
+				switch e.Event {
+				case sdl.WINDOWEVENT_CLOSE:
+					ui.Close() // Let game close that level channel
+				case sdl.WINDOWEVENT_SIZE_CHANGED:
+					// Every window hears this, so ask ours for its size
+					ui.resize(ui.window.GetSize())
 				}
+			case *sdl.ControllerDeviceEvent:
+				ui.controllerEvent(e)
 			}
 		}
 
 		ui.currentMouseState = getmouseState()
+		ui.reloadAssets()
 
 		// TODO(max): suspect quick keypresses sometimes cause channel gridlock
 		// Check if we have a new game state to draw
 		// ONLY executes when we get a new level from the channel
 		var ok bool
+		prevLevel := newLevel
 		select {
 		// Don't wait on the channel
-		case newLevel, ok = <-ui.levelChan:
-			if ok {
-				// Visibility into game events
-				switch newLevel.LastEvent {
-				case game.Move:
-					// Play footesteps upon walking
-					playRandomSound(ui.sounds.footsteps, 5)
-				case game.OpenDoor:
-					playRandomSound(ui.sounds.openingDoors, 10)
-				default:
-				}
+		case newLevel, ok = <-ui.Levels():
+			if !ok {
+				return // The game closed our window, eg. our hero died
+			}
+			ui.stepSent = false
+			ui.animate(prevLevel, newLevel)
+			// Visibility into game events
+			switch newLevel.LastEvent {
+			case game.Move:
+				// Play footesteps upon walking
+				playRandomSound(ui.sounds.footsteps, 5)
+			case game.OpenDoor:
+				playRandomSound(ui.sounds.openingDoors, 10)
+			default:
 			}
 		default:
 		}
 
 		ui.Draw(newLevel)
 		var input game.Input
-		if ui.state == UIInventory {
+		if newLevel.Player.Trading != nil {
+			if ui.draggedItem != nil && !ui.currentMouseState.leftButton && ui.prevMouseState.leftButton {
+				// Bought or sold
+				input.Typ = ui.CheckTradedItem(newLevel)
+				input.Item = ui.draggedItem
+				ui.draggedItem = nil
+			}
+			// Check if we are still dragging
+			if !ui.currentMouseState.leftButton || ui.draggedItem == nil {
+				ui.draggedItem = ui.CheckShopItems(newLevel)
+			}
+			ui.DrawShop(newLevel)
+		} else if ui.state == UIInventory {
 			if ui.draggedItem != nil && !ui.currentMouseState.leftButton && ui.prevMouseState.leftButton {
 				// Equipped
 				item := ui.CheckEquippedItem()
@@ -593,6 +622,15 @@ func (ui *ui) Run() {
 				ui.draggedItem = ui.CheckInventoryItems(newLevel)
 			}
 			ui.DrawInventory(newLevel)
+		} else if ui.state == UIJournal {
+			ui.DrawJournal(newLevel)
+		} else if ui.state == UIMap {
+			ui.DrawMap(newLevel)
+		} else if ui.state == UIBindings {
+			ui.DrawBindings()
+		}
+		if newLevel.Player.Conversation != nil {
+			ui.DrawDialogue(newLevel)
 		}
 		// TODO(max): calling present twice will cause flickering
 		ui.renderer.Present()
@@ -601,38 +639,69 @@ func (ui *ui) Run() {
 		if item != nil {
 			input.Typ = game.TakeItem
 			input.Item = item
+		} else if pos, ok := ui.CheckMapClick(newLevel); ok {
+			input.Typ = game.Travel
+			input.Pos = pos
 		}
 
-		// Handle keypresses if window is in focus
+		// Keys only count if the window is in focus
 		// Or else will crash because we are trying to send x3 input to all 3 windows at the same time
-		if sdl.GetKeyboardFocus() == ui.window && sdl.GetMouseFocus() == ui.window {
-
-			if ui.keyDownOnce(sdl.SCANCODE_UP) {
-				input.Typ = game.Up
-			} else if ui.keyDownOnce(sdl.SCANCODE_DOWN) {
-				input.Typ = game.Down
-			} else if ui.keyDownOnce(sdl.SCANCODE_LEFT) {
-				input.Typ = game.Left
-			} else if ui.keyDownOnce(sdl.SCANCODE_RIGHT) {
-				input.Typ = game.Right
-			} else if ui.keyDownOnce(sdl.SCANCODE_T) {
-				input.Typ = game.TakeAll
-			} else if ui.keyDownOnce(sdl.SCANCODE_I) {
-				if ui.state == UIMain {
-					ui.state = UIInventory
-				} else {
-					ui.state = UIMain
-				}
+		// Controllers belong to the window that heard them plugged in, so they work either way
+		focused := sdl.GetKeyboardFocus() == ui.window && sdl.GetMouseFocus() == ui.window
+		if !focused {
+			input.Typ = game.None // The click was meant for another window
+		}
+		ui.updateActions(focused)
+
+		if ui.state == UIBindings {
+			if focused {
+				ui.updateRebind()
+			}
+		} else if ui.actionPressed("Bindings") {
+			ui.toggleBindings()
+		} else if ui.actionPressed("Fullscreen") {
+			ui.toggleFullscreen()
+		} else if ui.actionPressed("ZoomIn") {
+			ui.setZoom(ui.zoom + 1)
+		} else if ui.actionPressed("ZoomOut") {
+			ui.setZoom(ui.zoom - 1)
+		} else if typ := ui.pressedInput(newLevel); typ != game.None {
+			input.Typ = typ
+		} else if ui.actionPressed("Journal") {
+			if ui.state == UIJournal {
+				ui.state = UIMain
+			} else {
+				ui.state = UIJournal
+			}
+		} else if ui.actionPressed("Map") {
+			if ui.state == UIMap {
+				ui.state = UIMain
+			} else {
+				ui.state = UIMap
+			}
+		} else if choice := ui.CheckDialogueChoice(); focused && choice >= 0 && newLevel.Player.Conversation != nil {
+			input.Typ = game.Choose
+			input.Choice = choice
+		} else if ui.actionPressed("Inventory") {
+			if ui.state == UIMain {
+				ui.state = UIInventory
+			} else {
+				ui.state = UIMain
 			}
+		}
 
-			// Update previous keyboard state
+		// Update previous keyboard state
+		if focused {
 			for i, v := range ui.keyboardState {
 				ui.prevKeyboardState[i] = v
 			}
+		}
 
-			if input.Typ != game.None {
-				ui.inputChan <- &input
-			}
+		if input.Typ != game.None {
+			ui.Send(&input) // Lets the game know which player this window controls
+		}
+		if input.Typ == game.None && newLevel.Player.Traveling() {
+			ui.keepTraveling()
 		}
 		ui.prevMouseState = ui.currentMouseState
 		sdl.Delay(10) // Don't eat cpu waiting for inputs
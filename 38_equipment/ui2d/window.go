@@ -0,0 +1,99 @@
+package ui2d
+
+import (
+	"math"
+
+	"github.com/maxproske/games-with-go/38_equipment/assets"
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+// Everything but the tiles is laid out as a fraction of the window, so it follows the window
+// around as it's resized. Tiles are zoomed separately, in whole steps so pixels stay square.
+
+// Size of a sprite in tiles.png
+const atlasTileSize = 32
+
+const maxZoom = 4
+
+// Smallest the window can be dragged to, below this the panels cover the map
+const (
+	minWinWidth  = 640
+	minWinHeight = 360
+)
+
+// The window size fonts were first picked for, they scale with it
+const (
+	baseWinWidth  = 1280
+	baseWinHeight = 720
+)
+
+// setZoom changes how big tiles are drawn, from 1 to maxZoom times their size in the atlas
+func (ui *ui) setZoom(zoom int) {
+	if zoom < 1 || zoom > maxZoom {
+		return
+	}
+	ui.zoom = zoom
+	ui.tileSize = atlasTileSize * zoom
+}
+
+// resize lays the UI out again after the window changed size
+func (ui *ui) resize(w, h int32) {
+	if int(w) == ui.winWidth && int(h) == ui.winHeight {
+		return
+	}
+	ui.winWidth = int(w)
+	ui.winHeight = int(h)
+	ui.loadFonts()
+}
+
+// toggleFullscreen switches between a window and the whole desktop
+func (ui *ui) toggleFullscreen() {
+	var flags uint32
+	if ui.window.GetFlags()&sdl.WINDOW_FULLSCREEN_DESKTOP != sdl.WINDOW_FULLSCREEN_DESKTOP {
+		flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+	err := ui.window.SetFullscreen(flags)
+	if err != nil {
+		panic(err)
+	}
+	// Don't wait for the size changed event, so the next frame is already laid out
+	ui.resize(ui.window.GetSize())
+}
+
+// loadFonts opens the fonts at sizes for the window, by whichever side is shorter
+// compared to the window they were made for, so text fits in a wide or tall window
+func (ui *ui) loadFonts() {
+	for _, font := range []*ttf.Font{ui.fontSmall, ui.fontMedium, ui.fontLarge} {
+		if font != nil {
+			font.Close()
+		}
+	}
+	if ui.fontData == nil {
+		ui.fontData = assets.UI.MustRead(fontFile)
+	}
+	scale := math.Min(float64(ui.winWidth)/baseWinWidth, float64(ui.winHeight)/baseWinHeight)
+	openFont := func(size float64) *ttf.Font {
+		font, err := ttf.OpenFontRW(rwops(ui.fontData), 1, int(math.Max(size*scale, 8)))
+		if err != nil {
+			panic(err)
+		}
+		return font
+	}
+	ui.fontSmall = openFont(baseWinWidth * 0.015)
+	ui.fontMedium = openFont(32)
+	ui.fontLarge = openFont(64)
+	ui.clearStringCache()
+}
+
+// clearStringCache throws away text rendered with the old fonts
+func (ui *ui) clearStringCache() {
+	for _, cache := range []map[string]*sdl.Texture{ui.str2TexSmall, ui.str2TexMedium, ui.str2TexLarge} {
+		for _, tex := range cache {
+			tex.Destroy()
+		}
+	}
+	ui.str2TexSmall = make(map[string]*sdl.Texture)
+	ui.str2TexMedium = make(map[string]*sdl.Texture)
+	ui.str2TexLarge = make(map[string]*sdl.Texture)
+}
@@ -0,0 +1,319 @@
+package uitty
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+)
+
+// The screen is a fixed 80x24 so it fits any terminal
+const (
+	screenWidth  = 80
+	screenHeight = 24
+	mapTop       = 1  // Row 0 is the status line
+	mapHeight    = 17 // Rows left over go to the event log
+	eventLines   = screenHeight - mapTop - mapHeight
+)
+
+// ANSI escape codes
+const (
+	escReset      = "\x1b[0m"
+	escHome       = "\x1b[H"
+	escClear      = "\x1b[2J"
+	escClearLine  = "\x1b[K"
+	escHideCursor = "\x1b[?25l"
+	escShowCursor = "\x1b[?25h"
+
+	colorDefault = ""
+	colorDim     = "\x1b[90m" // Remembered but not visible
+	colorWall    = "\x1b[37m"
+	colorFloor   = "\x1b[33m"
+	colorMonster = "\x1b[1;31m"
+	colorItem    = "\x1b[1;33m"
+	colorNPC     = "\x1b[1;36m"
+	colorTrap    = "\x1b[35m"
+	colorPlayer  = "\x1b[1;32m"
+	colorFriend  = "\x1b[32m"
+	colorText    = "\x1b[37m"
+	colorTitle   = "\x1b[1;33m"
+	colorChoice  = "\x1b[31m"
+	colorEvent   = "\x1b[31m"
+)
+
+type cell struct {
+	r     rune
+	color string
+}
+
+// frame is a screen's worth of cells, filled in any order and then written top to bottom
+type frame [screenHeight][screenWidth]cell
+
+func (f *frame) put(x, y int, r rune, color string) {
+	if x >= 0 && x < screenWidth && y >= 0 && y < screenHeight {
+		f[y][x] = cell{r, color}
+	}
+}
+
+// text writes a string from x, cutting it off at the edge of the screen
+func (f *frame) text(x, y int, s string, color string) {
+	for _, r := range s {
+		f.put(x, y, r, color)
+		x++
+	}
+}
+
+// write only changes colour when it has to, and clears anything left over from the last frame
+func (f *frame) write(w io.Writer) {
+	out := bufio.NewWriter(w)
+	out.WriteString(escHome)
+	for y, row := range f {
+		color := colorDefault
+		for _, c := range row {
+			if c.r == 0 {
+				c.r = ' '
+			}
+			if c.color != color {
+				out.WriteString(escReset + c.color)
+				color = c.color
+			}
+			out.WriteRune(c.r)
+		}
+		out.WriteString(escReset + escClearLine)
+		if y < screenHeight-1 {
+			out.WriteString("\r\n") // Raw mode doesn't return the cursor for us
+		}
+	}
+	out.Flush()
+}
+
+// tileRune picks what a tile looks like, the overlay wins if there is one
+func tileRune(tile game.Tile) (rune, string) {
+	r := tile.Rune
+	if tile.OverlayRune != game.Blank {
+		r = tile.OverlayRune
+	}
+	switch r {
+	case game.StoneWall, game.SecretDoor:
+		return '#', colorWall // Secret doors look like walls until they are found
+	case game.ClosedDoor, game.OpenDoor:
+		return r, colorWall
+	case game.UpStair:
+		return '<', colorText
+	case game.DownStair:
+		return '>', colorText
+	case game.DirtFloor:
+		return '.', colorFloor
+	}
+	return ' ', colorDefault
+}
+
+// Draw writes a whole frame for the level, with fog of war, the event log and any open panel
+func (ui *ui) Draw(w io.Writer, level *game.Level) {
+	var f frame
+	player := level.Player
+
+	// Keep the player in the middle of the map
+	offsetX := player.X - screenWidth/2
+	offsetY := player.Y - mapHeight/2
+	visible := func(pos game.Pos) bool {
+		return pos.Y >= 0 && pos.Y < len(level.Map) && pos.X >= 0 && pos.X < len(level.Map[pos.Y]) && level.Map[pos.Y][pos.X].Visible
+	}
+	draw := func(pos game.Pos, r rune, color string) {
+		y := pos.Y - offsetY
+		if y >= 0 && y < mapHeight {
+			f.put(pos.X-offsetX, y+mapTop, r, color)
+		}
+	}
+
+	// Draw tiles
+	for y, row := range level.Map {
+		for x, tile := range row {
+			if !tile.Visible && !tile.Seen {
+				continue
+			}
+			r, color := tileRune(tile)
+			if !tile.Visible {
+				color = colorDim
+			}
+			draw(game.Pos{X: x, Y: y}, r, color)
+		}
+	}
+
+	// Things on the map are only shown while you can see them
+	for pos, trap := range level.Traps {
+		if visible(pos) && !trap.Hidden {
+			draw(pos, trap.Rune, colorTrap)
+		}
+	}
+	for pos, items := range level.Items {
+		if visible(pos) && len(items) > 0 {
+			draw(pos, items[len(items)-1].Rune, colorItem) // Top of the pile
+		}
+	}
+	for pos, npc := range level.NPCs {
+		if visible(pos) {
+			draw(pos, npc.Rune, colorNPC)
+		}
+	}
+	for pos, monster := range level.Monsters {
+		if visible(pos) {
+			draw(pos, monster.Rune, colorMonster)
+		}
+	}
+	for _, other := range level.Players {
+		if visible(other.Pos) {
+			draw(other.Pos, other.Rune, colorFriend)
+		}
+	}
+	draw(player.Pos, player.Rune, colorPlayer)
+
+	// Status line
+	status := player.Name + "  HP " + strconv.Itoa(player.Hitpoints) + "/" + strconv.Itoa(player.MaxHitpoints) +
+		"  Gold " + strconv.Itoa(player.Gold) + "  " + level.Name + " (depth " + strconv.Itoa(level.Depth) + ")"
+	if items := level.Items[player.Pos]; len(items) > 0 {
+		status += "  Here:"
+		for _, item := range items {
+			status += " " + item.Name
+		}
+	}
+	f.text(0, 0, status, colorTitle)
+
+	// Newest events at the bottom, like the scrolling event console
+	events := make([]string, 0, len(level.Events))
+	for i := range level.Events {
+		event := level.Events[(level.EventPos+i)%len(level.Events)]
+		if event != "" {
+			events = append(events, event)
+		}
+	}
+	if len(events) > eventLines {
+		events = events[len(events)-eventLines:]
+	}
+	for i, event := range events {
+		f.text(0, mapTop+mapHeight+i, event, colorEvent)
+	}
+
+	// Panels go over the top of the map, the same order ui2d draws them
+	var p panel
+	if player.Trading != nil {
+		p = shopPanel(level)
+	} else if ui.state == uiInventory {
+		p = inventoryPanel(level)
+	} else if ui.state == uiJournal {
+		p = journalPanel(level)
+	}
+	if player.Conversation != nil {
+		p = dialoguePanel(level)
+	}
+	p.draw(&f)
+
+	f.write(w)
+}
+
+type panelLine struct {
+	s     string
+	color string
+}
+
+// panel is a box of text drawn over the map
+type panel []panelLine
+
+func (p *panel) add(s, color string) {
+	*p = append(*p, panelLine{s, color})
+}
+
+func (p panel) draw(f *frame) {
+	if len(p) == 0 {
+		return
+	}
+	const left, width = 4, screenWidth - 8
+	for i, line := range p {
+		y := mapTop + 1 + i
+		if y >= mapTop+mapHeight {
+			break
+		}
+		for x := left; x < left+width; x++ {
+			f.put(x, y, ' ', colorDefault) // Blank out the map behind the panel
+		}
+		f.text(left+1, y, line.s, line.color)
+	}
+}
+
+// letter labels the i'th item in a list, or a space once we run out of letters
+func letter(i int, base byte) string {
+	if i >= 26 {
+		return " "
+	}
+	return string(rune(base + byte(i)))
+}
+
+func dialoguePanel(level *game.Level) panel {
+	var p panel
+	conversation := level.Player.Conversation
+	p.add(conversation.NPC.Name+":", colorTitle)
+	for _, text := range conversation.Node.Text {
+		p.add(text, colorText)
+	}
+	p.add("", colorDefault)
+	for i, choice := range level.Player.AvailableChoices() {
+		p.add(strconv.Itoa(i+1)+". "+choice.Description, colorChoice)
+	}
+	return p
+}
+
+func shopPanel(level *game.Level) panel {
+	var p panel
+	player := level.Player
+	merchant := player.Trading
+	p.add(merchant.Name+"'s shop (a-z to buy, Esc to leave)", colorTitle)
+	for i, item := range merchant.Shop.Stock {
+		p.add(letter(i, 'a')+") "+item.Name+" - "+strconv.Itoa(item.Value)+" gold", colorText)
+	}
+	p.add("", colorDefault)
+	p.add(player.Name+": "+strconv.Itoa(player.Gold)+" gold (A-Z to sell)", colorTitle)
+	for i, item := range player.Items {
+		p.add(letter(i, 'A')+") "+item.Name+" - sells for "+strconv.Itoa(item.SellPrice())+" gold", colorText)
+	}
+	return p
+}
+
+func inventoryPanel(level *game.Level) panel {
+	var p panel
+	player := level.Player
+	p.add("Inventory (a-z to equip, A-Z to drop, Esc to close)", colorTitle)
+	if player.Helmet != nil {
+		p.add("Helmet: "+player.Helmet.Name, colorText)
+	}
+	if player.Weapon != nil {
+		p.add("Weapon: "+player.Weapon.Name, colorText)
+	}
+	for i, item := range player.Items {
+		p.add(letter(i, 'a')+") "+item.Name, colorText)
+	}
+	if len(player.Items) == 0 {
+		p.add("Nothing", colorDim)
+	}
+	return p
+}
+
+func journalPanel(level *game.Level) panel {
+	var p panel
+	p.add("Quests", colorTitle)
+	if len(level.Player.Quests) == 0 {
+		p.add("Nothing to do yet. Try talking to people.", colorText)
+	}
+	for _, quest := range level.Player.Quests {
+		if quest.Done {
+			p.add(quest.Name+" (complete)", colorDim)
+			continue
+		}
+		p.add(quest.Name, colorText)
+		p.add("  "+quest.Description, colorText)
+		for _, objective := range quest.Objectives {
+			p.add("  - "+objective.String(), colorChoice)
+		}
+	}
+	return p
+}
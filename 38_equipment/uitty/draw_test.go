@@ -0,0 +1,148 @@
+package uitty
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+)
+
+// Frames are checked against testdata/*.golden. After changing how things are
+// drawn, look at the new frames with cat and write them out with:
+//
+//	go test ./uitty -update
+var update = flag.Bool("update", false, "rewrite the golden frames")
+
+// testMap is seen up to x 11 and visible up to x 9, so the frame has fog of war in it.
+// + is a secret door nobody has found, it has to look like a wall.
+var testMap = []string{
+	"##############",
+	"#............#",
+	"#..+...|.....#",
+	"#........d...#",
+	"##############",
+}
+
+// testLevel is a small level made by hand, so the frames don't change when the maps do
+func testLevel() *game.Level {
+	level := &game.Level{
+		Name:     "level1",
+		Depth:    1,
+		Monsters: make(map[game.Pos]*game.Monster),
+		NPCs:     make(map[game.Pos]*game.NPC),
+		Items:    make(map[game.Pos][]*game.Item),
+		Traps:    make(map[game.Pos]*game.Trap),
+		Events:   []string{"Rat Attacked Ada for 3", "", "Ada entered level1 (depth 1)", "Ada picked up 1x Helmet"},
+		EventPos: 1,
+	}
+	level.Map = make([][]game.Tile, len(testMap))
+	for y, line := range testMap {
+		level.Map[y] = make([]game.Tile, len(line))
+		for x, c := range line {
+			tile := game.Tile{Rune: game.DirtFloor, Visible: x <= 9, Seen: x <= 11}
+			switch c {
+			case '#':
+				tile.Rune = game.StoneWall
+			case '+', '|', 'd':
+				tile.OverlayRune = c
+			}
+			level.Map[y][x] = tile
+		}
+	}
+
+	player := &game.Player{
+		Character: game.Character{
+			Entity:       game.Entity{Pos: game.Pos{X: 2, Y: 1}, Name: "Ada", Rune: '@'},
+			Hitpoints:    15,
+			MaxHitpoints: 20,
+			Items:        []*game.Item{game.NewHelmet(game.Pos{})},
+			Weapon:       game.NewSword(game.Pos{}),
+		},
+		Gold: 7,
+		Quests: []*game.Quest{
+			{Name: "Rat Catcher", Description: "The hermit wants the rats dealt with.", Objectives: []*game.Objective{
+				{Typ: game.KillObjective, Target: "Rat", Count: 1},
+			}},
+			{Name: "Lost and Found", Done: true},
+		},
+	}
+	friend := &game.Player{Character: game.Character{Entity: game.Entity{Pos: game.Pos{X: 1, Y: 3}, Name: "Bo", Rune: '@'}}}
+	level.Player = player
+	level.Players = []*game.Player{player, friend}
+
+	level.Monsters[game.Pos{X: 5, Y: 3}] = game.NewRat(game.Pos{X: 5, Y: 3})
+	level.Monsters[game.Pos{X: 11, Y: 1}] = game.NewSpider(game.Pos{X: 11, Y: 1}) // Out of sight
+	level.Items[game.Pos{X: 4, Y: 1}] = []*game.Item{game.NewSword(game.Pos{X: 4, Y: 1})}
+	found := game.NewSpikeTrap(game.Pos{X: 6, Y: 1})
+	found.Hidden = false
+	level.Traps[found.Pos] = found
+	level.Traps[game.Pos{X: 8, Y: 1}] = game.NewSpikeTrap(game.Pos{X: 8, Y: 1})
+	return level
+}
+
+func TestDrawGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		state uiState
+	}{
+		{"main", uiMain},
+		{"inventory", uiInventory},
+		{"journal", uiJournal},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ui := newUI(nil, nil, game.Channels{})
+			ui.state = test.state
+			var buf bytes.Buffer
+			ui.Draw(&buf, testLevel())
+
+			golden := filepath.Join("testdata", test.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("Frame doesn't match %s, got:\n%s", golden, plain(buf.String()))
+			}
+		})
+	}
+}
+
+// Frames are always a full screen, whatever is drawn
+func TestDrawSize(t *testing.T) {
+	var buf bytes.Buffer
+	newUI(nil, nil, game.Channels{}).Draw(&buf, testLevel())
+	lines := strings.Split(plain(buf.String()), "\r\n")
+	if len(lines) != screenHeight {
+		t.Fatalf("Frame has %d lines, want %d", len(lines), screenHeight)
+	}
+	for i, line := range lines {
+		if n := len([]rune(line)); n != screenWidth {
+			t.Fatalf("Line %d is %d wide, want %d", i, n, screenWidth)
+		}
+	}
+}
+
+// plain strips the escape codes out of a frame, so it can be read in a test failure
+func plain(frame string) string {
+	var sb strings.Builder
+	for i := 0; i < len(frame); i++ {
+		if frame[i] != '\x1b' {
+			sb.WriteByte(frame[i])
+			continue
+		}
+		// Skip to the letter that ends the escape code
+		for i++; i < len(frame) && !(frame[i] >= 'A' && frame[i] <= 'Z' || frame[i] >= 'a' && frame[i] <= 'z'); i++ {
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package uitty
+
+import "errors"
+
+// makeRaw isn't supported here, so keys only arrive after Enter
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("uitty: raw mode isn't supported on this platform")
+}
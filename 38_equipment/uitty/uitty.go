@@ -0,0 +1,213 @@
+// Package uitty plays the game in a terminal, for when there's no window to
+// open, eg. over SSH. It takes the same channels as ui2d.
+package uitty
+
+import (
+	"io"
+	"os"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+)
+
+type uiState int
+
+const (
+	uiMain uiState = iota
+	uiInventory
+	uiJournal
+)
+
+type ui struct {
+	game.Channels
+	state uiState
+	in    io.Reader
+	out   io.Writer
+	level *game.Level // Last level we were sent, to work out what keys mean
+}
+
+func init() {
+	game.RegisterFrontend("tty", func(channels game.Channels) game.Frontend {
+		return NewUI(channels)
+	})
+}
+
+// NewUI creates a UI that draws to stdout and reads keys from stdin
+func NewUI(channels game.Channels) *ui {
+	return newUI(os.Stdin, os.Stdout, channels)
+}
+
+func newUI(in io.Reader, out io.Writer, channels game.Channels) *ui {
+	return &ui{Channels: channels, state: uiMain, in: in, out: out}
+}
+
+// Run draws every level it is sent, and turns keys into inputs until the game is over
+func (ui *ui) Run() {
+	if file, ok := ui.in.(*os.File); ok {
+		// Without raw mode keys only arrive after Enter, but the game is still playable
+		if restore, err := makeRaw(int(file.Fd())); err == nil {
+			defer restore()
+		}
+	}
+	io.WriteString(ui.out, escHideCursor+escClear)
+	defer io.WriteString(ui.out, escReset+escShowCursor+"\r\n")
+
+	keys := make(chan string)
+	go ui.readKeys(keys)
+
+	for {
+		select {
+		case level, ok := <-ui.Levels():
+			if !ok {
+				return // Our window was closed
+			}
+			ui.level = level
+			ui.Draw(ui.out, level)
+		case key, ok := <-keys:
+			if !ok {
+				// Nobody left at the keyboard
+				ui.Close()
+				keys = nil
+				continue
+			}
+			if ui.level == nil {
+				continue // Nothing to play yet
+			}
+			input := ui.handleKey(key)
+			if input.Typ == game.None {
+				ui.Draw(ui.out, ui.level) // A panel may have opened or closed
+				continue
+			}
+			ui.Send(input)
+			if input.Typ == game.QuitGame {
+				return
+			}
+		}
+	}
+}
+
+// readKeys splits whatever comes in from the keyboard into single keys
+func (ui *ui) readKeys(keys chan string) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := ui.in.Read(buf)
+		for _, key := range splitKeys(string(buf[:n])) {
+			keys <- key
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// splitKeys separates escape sequences, like arrow keys, from ordinary keys.
+// Pasting or typing fast can put several keys in one read.
+func splitKeys(s string) []string {
+	keys := make([]string, 0, len(s))
+	for len(s) > 0 {
+		n := 1
+		if s[0] == '\x1b' && len(s) > 2 && (s[1] == '[' || s[1] == 'O') {
+			// CSI ends on a letter or ~, SS3 is always one more byte
+			n = 3
+			if s[1] == '[' {
+				for n = 2; n < len(s) && !(s[n] >= 'A' && s[n] <= 'Z' || s[n] >= 'a' && s[n] <= 'z' || s[n] == '~'); n++ {
+				}
+				if n < len(s) {
+					n++
+				}
+			}
+		}
+		keys = append(keys, s[:n])
+		s = s[n:]
+	}
+	return keys
+}
+
+// handleKey turns a key into an input, or changes what panel is open
+func (ui *ui) handleKey(key string) *game.Input {
+	input := &game.Input{}
+	player := ui.level.Player
+
+	// Letters pick items while a list is open
+	if len(key) == 1 && (key[0] >= 'a' && key[0] <= 'z' || key[0] >= 'A' && key[0] <= 'Z') {
+		lower := key[0] >= 'a'
+		i := int(key[0] - 'A')
+		if lower {
+			i = int(key[0] - 'a')
+		}
+		if player.Trading != nil {
+			if lower && i < len(player.Trading.Shop.Stock) {
+				input.Typ = game.Buy
+				input.Item = player.Trading.Shop.Stock[i]
+			} else if !lower && i < len(player.Items) {
+				input.Typ = game.Sell
+				input.Item = player.Items[i]
+			}
+			return input
+		}
+		if ui.state == uiInventory && key != "i" {
+			if i < len(player.Items) {
+				input.Item = player.Items[i]
+				if lower {
+					input.Typ = game.EquipItem
+				} else {
+					input.Typ = game.DropItem
+				}
+			}
+			return input
+		}
+	}
+
+	switch key {
+	case "\x1b[A", "\x1bOA":
+		input.Typ = game.Up
+	case "\x1b[B", "\x1bOB":
+		input.Typ = game.Down
+	case "\x1b[D", "\x1bOD":
+		input.Typ = game.Left
+	case "\x1b[C", "\x1bOC":
+		input.Typ = game.Right
+	case "t":
+		input.Typ = game.TakeAll
+	case "s":
+		input.Typ = game.Search
+	case ">", ".":
+		input.Typ = game.Descend
+	case "<", ",":
+		input.Typ = game.Ascend
+	case "c":
+		input.Typ = game.Talk
+	case "\x1b":
+		if player.Trading != nil {
+			input.Typ = game.LeaveShop
+		} else {
+			ui.state = uiMain
+		}
+	case "i":
+		if ui.state == uiInventory {
+			ui.state = uiMain
+		} else {
+			ui.state = uiInventory
+		}
+	case "j":
+		if ui.state == uiJournal {
+			ui.state = uiMain
+		} else {
+			ui.state = uiJournal
+		}
+	case "\x1b[15~":
+		input.Typ = game.SaveGame // F5
+	case "\x1b[20~":
+		input.Typ = game.LoadGame // F9
+	case "q":
+		input.Typ = game.CloseWindow // Leave, and let everyone else keep playing
+	case "\x03":
+		input.Typ = game.QuitGame // Ctrl+C
+	default:
+		if player.Conversation != nil && len(key) == 1 && key[0] >= '1' && key[0] <= '9' {
+			input.Typ = game.Choose
+			input.Choice = int(key[0] - '1')
+		}
+	}
+	return input
+}
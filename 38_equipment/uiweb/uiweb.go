@@ -0,0 +1,297 @@
+// Package uiweb plays the game in a browser. It serves a canvas client that
+// draws the same tiles as ui2d, sends it each level over a WebSocket, and
+// turns the keys it sends back into inputs. It takes the same channels as ui2d.
+package uiweb
+
+import (
+	"bytes"
+	_ "embed" // For the client page
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/maxproske/games-with-go/38_equipment/assets"
+	"github.com/maxproske/games-with-go/38_equipment/game"
+)
+
+// Addr is where the first web UI listens. Only this machine can connect.
+// When it's taken, eg. by another player's UI, any free port is used instead.
+var Addr = "localhost:8080"
+
+// The assets ui2d loads, so the browser draws the same sprites
+const (
+	atlasFile = "assets/ui/atlas.txt"
+	tilesFile = "assets/ui/tiles.png"
+)
+
+//go:embed client.html
+var clientPage []byte
+
+// Message is an input from the browser. Index picks an item from one of the
+// lists in the last View, or a dialogue choice.
+type Message struct {
+	Typ   string
+	Index int
+}
+
+// Inputs the browser can send by name
+var inputTypes = map[string]game.InputType{
+	"Up":          game.Up,
+	"Down":        game.Down,
+	"Left":        game.Left,
+	"Right":       game.Right,
+	"TakeAll":     game.TakeAll,
+	"TakeItem":    game.TakeItem,
+	"DropItem":    game.DropItem,
+	"EquipItem":   game.EquipItem,
+	"Search":      game.Search,
+	"Descend":     game.Descend,
+	"Ascend":      game.Ascend,
+	"Talk":        game.Talk,
+	"Choose":      game.Choose,
+	"Buy":         game.Buy,
+	"Sell":        game.Sell,
+	"LeaveShop":   game.LeaveShop,
+	"SaveGame":    game.SaveGame,
+	"LoadGame":    game.LoadGame,
+	"CloseWindow": game.CloseWindow, // Leave, and let everyone else keep playing
+}
+
+type ui struct {
+	game.Channels
+	listener net.Listener
+	done     chan struct{} // Closed once the game is done with us
+
+	mu      sync.Mutex
+	level   *game.Level // Last level we were sent, to work out what inputs mean
+	view    []byte      // The same level as JSON, for browsers that connect late
+	clients map[*client]bool
+	closed  bool // A browser has closed our window, so the game will ignore anything else we send
+}
+
+// client is one browser tab. Any number can watch and play the same player.
+type client struct {
+	conn *Conn
+	out  chan []byte // Latest view only, so a slow tab skips ahead instead of holding up the rest
+}
+
+func init() {
+	game.RegisterFrontend("web", func(channels game.Channels) game.Frontend {
+		return NewUI(channels)
+	})
+}
+
+// NewUI starts listening on Addr, or any free port if that's taken.
+// The game doesn't wait for a browser to connect, it carries on without one.
+func NewUI(channels game.Channels) *ui {
+	listener, err := net.Listen("tcp", Addr)
+	if err != nil {
+		host, _, splitErr := net.SplitHostPort(Addr)
+		if splitErr != nil {
+			panic(err)
+		}
+		listener, err = net.Listen("tcp", net.JoinHostPort(host, "0"))
+		if err != nil {
+			panic(err)
+		}
+	}
+	fmt.Println("Play in your browser at http://" + listener.Addr().String() + "/")
+	return newUI(channels, listener)
+}
+
+func newUI(channels game.Channels, listener net.Listener) *ui {
+	return &ui{
+		Channels: channels,
+		listener: listener,
+		done:     make(chan struct{}),
+		clients:  make(map[*client]bool),
+	}
+}
+
+// Addr is the address the UI is serving on
+func (ui *ui) Addr() string {
+	return ui.listener.Addr().String()
+}
+
+// serveAsset sends the browser a file ui2d uses, read fresh each time so -assets edits show on reload
+func serveAsset(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := assets.UI.Read(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, path.Base(name), time.Time{}, bytes.NewReader(data))
+	}
+}
+
+// Run serves the client and sends every level to every browser until our window is closed
+func (ui *ui) Run() {
+	server := &http.Server{Handler: ui.handler()}
+	go server.Serve(ui.listener)
+	defer server.Close()
+	ui.play()
+}
+
+// handler serves the client page, the sprites it draws and the websocket
+func (ui *ui) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(clientPage)
+	})
+	mux.HandleFunc("/atlas.txt", serveAsset(atlasFile))
+	mux.HandleFunc("/tiles.png", serveAsset(tilesFile))
+	mux.HandleFunc("/ws", ui.serveWebsocket)
+	return mux
+}
+
+// play sends every level to every browser, then hangs up on them once the game is done with us
+func (ui *ui) play() {
+	for level := range ui.Levels() {
+		ui.show(level)
+	}
+
+	close(ui.done)
+	ui.mu.Lock()
+	for c := range ui.clients {
+		ui.drop(c)
+	}
+	ui.mu.Unlock()
+}
+
+// show hands every browser the new level
+func (ui *ui) show(level *game.Level) {
+	view, err := json.Marshal(newView(level))
+	if err != nil {
+		panic(err)
+	}
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.level = level
+	ui.view = view
+	for c := range ui.clients {
+		c.send(view)
+	}
+}
+
+// send replaces whatever view the browser hasn't been sent yet. Only called with ui.mu held.
+func (c *client) send(view []byte) {
+	select {
+	case <-c.out:
+	default:
+	}
+	c.out <- view
+}
+
+// drop stops sending to a browser. Only called with ui.mu held.
+func (ui *ui) drop(c *client) {
+	if ui.clients[c] {
+		delete(ui.clients, c)
+		close(c.out)
+	}
+}
+
+func (ui *ui) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrade(w, r)
+	if err != nil {
+		return // upgrade has already told the browser what went wrong
+	}
+	c := &client{conn, make(chan []byte, 1)}
+
+	ui.mu.Lock()
+	select {
+	case <-ui.done:
+		ui.mu.Unlock()
+		conn.Close()
+		return
+	default:
+	}
+	ui.clients[c] = true
+	if ui.view != nil {
+		c.send(ui.view) // Don't make them wait for the next turn to see anything
+	}
+	ui.mu.Unlock()
+
+	go func() {
+		defer conn.Close()
+		for view := range c.out {
+			if conn.WriteMessage(view) != nil {
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		ui.mu.Lock()
+		ui.drop(c)
+		ui.mu.Unlock()
+	}()
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return // Tab closed, the player waits where they are until it comes back
+		}
+		var msg Message
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		input := ui.input(msg)
+		if input == nil {
+			continue
+		}
+		select {
+		case ui.Inputs() <- ui.From(input):
+		case <-ui.done:
+			return
+		}
+	}
+}
+
+// input turns a message into an input, or nil if it doesn't make sense any more
+func (ui *ui) input(msg Message) *game.Input {
+	typ, ok := inputTypes[msg.Typ]
+	if !ok {
+		return nil
+	}
+	ui.mu.Lock()
+	level := ui.level
+	if level == nil || ui.closed {
+		ui.mu.Unlock()
+		return nil // Nothing to play yet, or nothing left to play
+	}
+	ui.closed = typ == game.CloseWindow
+	ui.mu.Unlock()
+
+	input := &game.Input{Typ: typ}
+	player := level.Player
+	pick := func(items []*game.Item) bool {
+		if msg.Index < 0 || msg.Index >= len(items) {
+			return false
+		}
+		input.Item = items[msg.Index]
+		return true
+	}
+	switch typ {
+	case game.TakeItem:
+		ok = pick(level.Items[player.Pos])
+	case game.DropItem, game.EquipItem, game.Sell:
+		ok = pick(player.Items)
+	case game.Buy:
+		ok = player.Trading != nil && pick(player.Trading.Shop.Stock)
+	case game.Choose:
+		input.Choice = msg.Index
+	}
+	if !ok {
+		return nil
+	}
+	return input
+}
@@ -0,0 +1,183 @@
+package uiweb
+
+import (
+	"github.com/maxproske/games-with-go/38_equipment/game"
+)
+
+// View is what the browser is sent for each level, as JSON. Levels point back
+// at themselves through dialogue and portals, so they can't be sent as they are.
+type View struct {
+	Name     string
+	Depth    int
+	Tiles    [][]Tile
+	Sprites  []Sprite // Everything standing on the map that the player can see, in the order to draw it
+	Player   PlayerView
+	Here     []ItemView // Items under the player's feet
+	Events   []string   // Oldest first
+	Dialogue *DialogueView
+	Shop     *ShopView
+}
+
+// Tile is one square of the map, left empty if the player has never seen it
+type Tile struct {
+	Rune    string `json:"R,omitempty"`
+	Overlay string `json:"O,omitempty"`
+	Visible bool   `json:"V,omitempty"`
+	Seen    bool   `json:"S,omitempty"`
+}
+
+// Sprite is something drawn over the tiles
+type Sprite struct {
+	X, Y  int
+	Image string // Name of its sprite in the atlas
+}
+
+// PlayerView is the player's stats, inventory and quests
+type PlayerView struct {
+	Sprite
+	Name         string
+	Hitpoints    int
+	MaxHitpoints int
+	Gold         int
+	Helmet       string
+	Weapon       string
+	Items        []ItemView
+	Quests       []QuestView
+}
+
+// ItemView is an item in a list, where its index is what an input picks
+type ItemView struct {
+	Name      string
+	Rune      string
+	Value     int
+	SellPrice int
+}
+
+// QuestView is a quest as the journal shows it
+type QuestView struct {
+	Name        string
+	Description string
+	Done        bool
+	Objectives  []string
+}
+
+// DialogueView is what an NPC is saying, and what the player can say back
+type DialogueView struct {
+	NPC     string
+	Text    []string
+	Choices []string
+}
+
+// ShopView is the stock of the merchant the player is trading with
+type ShopView struct {
+	Name  string
+	Stock []ItemView
+}
+
+// Players are all drawn with the same sprite, their names are whatever they chose
+const playerSprite = "Player"
+
+func newView(level *game.Level) *View {
+	player := level.Player
+	view := &View{Name: level.Name, Depth: level.Depth}
+
+	view.Tiles = make([][]Tile, len(level.Map))
+	for y, row := range level.Map {
+		view.Tiles[y] = make([]Tile, len(row))
+		for x, tile := range row {
+			if !tile.Visible && !tile.Seen {
+				continue // Don't give away what the player hasn't found
+			}
+			t := Tile{Visible: tile.Visible, Seen: tile.Seen}
+			if tile.Rune != game.Blank {
+				t.Rune = string(tile.Rune)
+			}
+			if tile.OverlayRune != game.Blank {
+				t.Overlay = string(tile.OverlayRune)
+			}
+			view.Tiles[y][x] = t
+		}
+	}
+
+	// Same order ui2d draws them in, so the same things end up on top
+	visible := func(pos game.Pos) bool {
+		return level.Map[pos.Y][pos.X].Visible
+	}
+	for pos, trap := range level.Traps {
+		if visible(pos) && !trap.Hidden {
+			view.Sprites = append(view.Sprites, Sprite{pos.X, pos.Y, trap.Name})
+		}
+	}
+	for pos, items := range level.Items {
+		if visible(pos) {
+			for _, item := range items {
+				view.Sprites = append(view.Sprites, Sprite{pos.X, pos.Y, item.Name})
+			}
+		}
+	}
+	for pos, monster := range level.Monsters {
+		if visible(pos) {
+			view.Sprites = append(view.Sprites, Sprite{pos.X, pos.Y, monster.Name})
+		}
+	}
+	for pos, npc := range level.NPCs {
+		if visible(pos) {
+			view.Sprites = append(view.Sprites, Sprite{pos.X, pos.Y, npc.Name})
+		}
+	}
+	for _, other := range level.Players {
+		if other.Pos != player.Pos && visible(other.Pos) {
+			view.Sprites = append(view.Sprites, Sprite{other.X, other.Y, playerSprite})
+		}
+	}
+
+	view.Player = PlayerView{
+		Sprite:       Sprite{player.X, player.Y, playerSprite},
+		Name:         player.Name,
+		Hitpoints:    player.Hitpoints,
+		MaxHitpoints: player.MaxHitpoints,
+		Gold:         player.Gold,
+		Items:        itemViews(player.Items),
+	}
+	if player.Helmet != nil {
+		view.Player.Helmet = player.Helmet.Name
+	}
+	if player.Weapon != nil {
+		view.Player.Weapon = player.Weapon.Name
+	}
+	for _, quest := range player.Quests {
+		q := QuestView{Name: quest.Name, Description: quest.Description, Done: quest.Done}
+		for _, objective := range quest.Objectives {
+			q.Objectives = append(q.Objectives, objective.String())
+		}
+		view.Player.Quests = append(view.Player.Quests, q)
+	}
+	view.Here = itemViews(level.Items[player.Pos])
+
+	for i := range level.Events {
+		event := level.Events[(level.EventPos+i)%len(level.Events)]
+		if event != "" {
+			view.Events = append(view.Events, event)
+		}
+	}
+
+	if player.Conversation != nil {
+		dialogue := &DialogueView{NPC: player.Conversation.NPC.Name, Text: player.Conversation.Node.Text}
+		for _, choice := range player.AvailableChoices() {
+			dialogue.Choices = append(dialogue.Choices, choice.Description)
+		}
+		view.Dialogue = dialogue
+	}
+	if player.Trading != nil {
+		view.Shop = &ShopView{player.Trading.Name, itemViews(player.Trading.Shop.Stock)}
+	}
+	return view
+}
+
+func itemViews(items []*game.Item) []ItemView {
+	views := make([]ItemView, len(items))
+	for i, item := range items {
+		views[i] = ItemView{item.Name, string(item.Rune), item.Value, item.SellPrice()}
+	}
+	return views
+}
@@ -0,0 +1,357 @@
+package uiweb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maxproske/games-with-go/38_equipment/game"
+)
+
+const timeout = 5 * time.Second
+
+// wsURL turns a test server's address into where its websocket is
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+}
+
+// startUI serves a web UI for one of the game's players from a test server
+func startUI(t *testing.T, g *game.Game, i int) *httptest.Server {
+	t.Helper()
+	ui := newUI(g.Channels(i), nil)
+	srv := httptest.NewServer(ui.handler())
+	t.Cleanup(srv.Close)
+	go ui.play()
+	return srv
+}
+
+func dialWS(t *testing.T, srv *httptest.Server) *Conn {
+	t.Helper()
+	conn, err := Dial(wsURL(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.conn.SetDeadline(time.Now().Add(timeout))
+	t.Cleanup(func() { conn.conn.Close() })
+	return conn
+}
+
+func readView(t *testing.T, conn *Conn) *View {
+	t.Helper()
+	data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var view View
+	if err := json.Unmarshal(data, &view); err != nil {
+		t.Fatal(err)
+	}
+	return &view
+}
+
+func sendMessage(t *testing.T, conn *Conn, msg Message) {
+	t.Helper()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrade(w, r)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	conn, err := Dial(wsURL(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// Requests upgrade has to turn away, and what it should say
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"not an upgrade", map[string]string{}, http.StatusBadRequest},
+		{"old version", map[string]string{"Upgrade": "websocket", "Connection": "Upgrade", "Sec-WebSocket-Version": "8", "Sec-WebSocket-Key": "x"}, http.StatusUpgradeRequired},
+		{"no key", map[string]string{"Upgrade": "websocket", "Connection": "Upgrade", "Sec-WebSocket-Version": "13"}, http.StatusBadRequest},
+		{"other origin", map[string]string{"Upgrade": "websocket", "Connection": "Upgrade", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "x", "Origin": "http://example.com"}, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range test.header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: got %s, want %d", test.name, resp.Status, test.status)
+		}
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example from RFC 6455
+	if key := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); key != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("acceptKey gave %s", key)
+	}
+}
+
+// pipe is a client and server talking to each other without a network
+func pipe() (client, server *Conn) {
+	c, s := net.Pipe()
+	return &Conn{conn: c, r: bufio.NewReader(c), client: true}, &Conn{conn: s, r: bufio.NewReader(s)}
+}
+
+func TestFraming(t *testing.T) {
+	client, server := pipe()
+	defer client.conn.Close()
+	defer server.conn.Close()
+
+	// One of each length encoding: 7 bits, 16 bits and 64 bits
+	sizes := []int{0, 5, 125, 126, 200, 0xffff, 0x10000, 70000}
+	go func() {
+		for {
+			msg, err := server.ReadMessage()
+			if err != nil {
+				return
+			}
+			server.WriteMessage(msg) // Echo it back unmasked
+		}
+	}()
+	for _, size := range sizes {
+		want := bytes.Repeat([]byte("x"), size)
+		if err := client.WriteMessage(want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := client.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Sent %d bytes, got %d back", size, len(got))
+		}
+	}
+}
+
+func TestMasking(t *testing.T) {
+	client, server := pipe()
+	defer client.conn.Close()
+	defer server.conn.Close()
+
+	// Clients have to mask, so read what it writes by hand
+	go client.WriteMessage([]byte("hello"))
+	var header [2]byte
+	io.ReadFull(server.conn, header[:])
+	if header[0] != 0x80|opText {
+		t.Fatalf("First byte %x, want a final text frame", header[0])
+	}
+	if header[1] != 0x80|5 {
+		t.Fatalf("Second byte %x, want masked and 5 long", header[1])
+	}
+	var mask [4]byte
+	payload := make([]byte, 5)
+	io.ReadFull(server.conn, mask[:])
+	io.ReadFull(server.conn, payload)
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	if string(payload) != "hello" {
+		t.Fatalf("Unmasked %q", payload)
+	}
+}
+
+func TestPingAndClose(t *testing.T) {
+	client, server := pipe()
+	defer client.conn.Close()
+	defer server.conn.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		// Pongs are skipped, so the first message back is the text after the ping
+		msg, err := client.ReadMessage()
+		if err == nil && string(msg) != "after ping" {
+			t.Errorf("Client read %q", msg)
+		}
+		// The close echoed back ends the stream
+		_, err = client.ReadMessage()
+		errs <- err
+	}()
+
+	go func() {
+		client.writeFrame(opPing, []byte("are you there"))
+		client.writeFrame(opClose, []byte{0x03, 0xe8})
+	}()
+	server.WriteMessage([]byte("after ping"))
+	if _, err := server.ReadMessage(); err != io.EOF {
+		t.Fatalf("Server read %v after a close, want EOF", err)
+	}
+	server.conn.Close() // Nobody reads the client's own echo of the close
+	select {
+	case err := <-errs:
+		if err != io.EOF {
+			t.Fatalf("Client read %v after the close was echoed, want EOF", err)
+		}
+	case <-time.After(timeout):
+		t.Fatal("Client never saw the close")
+	}
+}
+
+func TestTooBig(t *testing.T) {
+	client, server := pipe()
+	defer client.conn.Close()
+	defer server.conn.Close()
+
+	go func() {
+		frame := []byte{0x80 | opText, 0x80 | 127, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(frame[2:], maxMessage+1)
+		client.conn.Write(frame)
+	}()
+	if _, err := server.ReadMessage(); err != errTooBig {
+		t.Fatalf("Read %v, want %v", err, errTooBig)
+	}
+}
+
+// Levels go out as views, inputs come back as messages, and closing hangs up
+func TestLevelStreaming(t *testing.T) {
+	inTempDir(t)
+	g := game.NewGame(1)
+	srv := startUI(t, g, 0)
+	done := make(chan struct{})
+	go func() {
+		g.Run()
+		close(done)
+	}()
+
+	conn := dialWS(t, srv)
+	view := readView(t, conn)
+	if view.Name == "" || len(view.Tiles) == 0 || view.Player.Name == "" {
+		t.Fatalf("First view is missing the level: %+v", view)
+	}
+
+	sendMessage(t, conn, Message{Typ: "Search"})
+	next := readView(t, conn)
+	if next.Player.Name != view.Player.Name {
+		t.Fatalf("Sent %s's view, then %s's", view.Player.Name, next.Player.Name)
+	}
+
+	// A second tab watching the same player is sent the latest view straight away
+	other := dialWS(t, srv)
+	readView(t, other)
+
+	sendMessage(t, conn, Message{Typ: "CloseWindow"})
+	for _, c := range []*Conn{conn, other} {
+		for {
+			if _, err := c.ReadMessage(); err != nil {
+				break // Hung up on once the game let go of the player
+			}
+		}
+	}
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatal("Game didn't end after the only window closed")
+	}
+}
+
+// Bots play through the web frontend, over websockets like a browser, to race test it:
+//
+//	go test -race -run WebBots ./uiweb
+func TestWebBots(t *testing.T) {
+	inTempDir(t)
+	const players, turns = 3, 100
+	g := game.NewGame(players)
+	var wg sync.WaitGroup
+	for i := 0; i < players; i++ {
+		conn := dialWS(t, startUI(t, g, i))
+		conn.conn.SetDeadline(time.Now().Add(time.Minute))
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			webBot(conn, turns, rand.New(rand.NewSource(int64(i))))
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		g.Run() // Returns once every bot has closed its window
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Minute):
+		t.Fatal("Game didn't finish, something is stuck")
+	}
+}
+
+// Names the web frontend knows moves by, only ones that can't go stale
+var webMoves = []string{"Up", "Down", "Left", "Right", "Up", "Down", "Left", "Right", "TakeAll", "Search", "Talk", "LeaveShop"}
+
+// webBot plays like a browser, sending one key for each view it is sent
+func webBot(conn *Conn, turns int, r *rand.Rand) {
+	for sent := 0; ; sent++ {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return // The UI hangs up once the game has closed its window
+		}
+		var view View
+		if err := json.Unmarshal(data, &view); err != nil {
+			panic(err)
+		}
+		if sent > turns {
+			continue // Already left, keep reading until we're hung up on
+		}
+		msg := Message{Typ: webMoves[r.Intn(len(webMoves))]}
+		if sent == turns || view.Player.Hitpoints < view.Player.MaxHitpoints/2 {
+			msg.Typ = "CloseWindow" // Leave before the monsters end the run
+			sent = turns
+		}
+		data, err = json.Marshal(msg)
+		if err != nil {
+			panic(err)
+		}
+		if err := conn.WriteMessage(data); err != nil {
+			return
+		}
+	}
+}
+
+// inTempDir runs the rest of the test somewhere it can write files, anyone who dies leaves a morgue file
+func inTempDir(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}